@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/auth"
+	"github.com/SoulOppen/chirpy_go_server/internal/mailer"
+)
+
+// envDuration reads a Go duration such as "10m" from the environment,
+// falling back to def when the variable is unset.
+func envDuration(name string, def time.Duration) (time.Duration, error) {
+	s := os.Getenv(name)
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration, got %q", name, s)
+	}
+	return d, nil
+}
+
+// envDurationInRange is envDuration with the value required to fall within
+// [lo, hi].
+func envDurationInRange(name string, def, lo, hi time.Duration) (time.Duration, error) {
+	d, err := envDuration(name, def)
+	if err != nil {
+		return 0, err
+	}
+	if d < lo || d > hi {
+		return 0, fmt.Errorf("%s must be between %s and %s, got %s", name, lo, hi, d)
+	}
+	return d, nil
+}
+
+// envInt reads a positive integer from the environment, falling back to def
+// when the variable is unset.
+func envInt(name string, def int) (int, error) {
+	s := os.Getenv(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", name, s)
+	}
+	return n, nil
+}
+
+// argon2Params reads ARGON2_MEMORY_KIB, ARGON2_ITERATIONS and
+// ARGON2_PARALLELISM on top of auth.DefaultArgon2Params.
+func argon2Params() (auth.Argon2Params, error) {
+	p := auth.DefaultArgon2Params
+	memory, err := envInt("ARGON2_MEMORY_KIB", int(p.Memory))
+	if err != nil {
+		return p, err
+	}
+	iterations, err := envInt("ARGON2_ITERATIONS", int(p.Iterations))
+	if err != nil {
+		return p, err
+	}
+	parallelism, err := envInt("ARGON2_PARALLELISM", int(p.Parallelism))
+	if err != nil {
+		return p, err
+	}
+	if parallelism > 255 {
+		return p, fmt.Errorf("ARGON2_PARALLELISM must be at most 255, got %d", parallelism)
+	}
+	p.Memory = uint32(memory)
+	p.Iterations = uint32(iterations)
+	p.Parallelism = uint8(parallelism)
+	return p, nil
+}
+
+// envBool reads a boolean such as "true" or "0" from the environment,
+// falling back to def when the variable is unset.
+func envBool(name string, def bool) (bool, error) {
+	s := os.Getenv(name)
+	if s == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean, got %q", name, s)
+	}
+	return b, nil
+}
+
+// newMailer sends through SMTP when SMTP_ADDR is set. Otherwise messages are
+// written to .eml files, but only when asked for with MAIL_DIR or on the dev
+// platform (under the temp directory), since they hold live tokens. The
+// directory must not be inside the working directory, which /app/ serves.
+func newMailer(platform string) (mailer.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "chirpy@localhost"
+	}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return &mailer.SMTP{
+			Addr:     addr,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	}
+	dir := os.Getenv("MAIL_DIR")
+	if dir == "" {
+		if platform != "dev" {
+			return nil, errors.New("SMTP_ADDR must be set, or MAIL_DIR to write mail to files")
+		}
+		dir = filepath.Join(os.TempDir(), "chirpy-mail")
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	served, err := filepath.Abs(".")
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(served, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("MAIL_DIR %s is inside the served directory %s", dir, served)
+	}
+	return &mailer.File{Dir: dir, From: from}, nil
+}
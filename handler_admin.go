@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/auth"
+	"github.com/SoulOppen/chirpy_go_server/internal/database"
+	"github.com/google/uuid"
+)
+
+// PUT /admin/users/{userID}/role
+func (cfg *apiConfig) handlerSetRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "id is not uuid")
+		return
+	}
+	var params struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, 400, "Invalid JSON body")
+		return
+	}
+	if !auth.ValidRole(params.Role) {
+		respondWithError(w, 400, "role must be user, moderator or admin")
+		return
+	}
+	user, err := cfg.db.UpdateUserRole(r.Context(), database.UpdateUserRoleParams{
+		ID:   userID,
+		Role: params.Role,
+	})
+	if err != nil {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+	respondWithJSON(w, 200, userFromDB(user))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/database"
+	"github.com/google/uuid"
+)
+
+type ChirpRevision struct {
+	ID        uuid.UUID `json:"id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PATCH /api/chirps/{chirpID}
+func (cfg *apiConfig) handlerEditChirp(w http.ResponseWriter, r *http.Request) {
+	chirp, ok := cfg.ownedChirp(w, r)
+	if !ok {
+		return
+	}
+	if !cfg.requireVerifiedEmail(w, r, chirp.UserID) {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	body := parameters{}
+	if err := decoder.Decode(&body); err != nil {
+		respondWithError(w, 400, "Invalid JSON body")
+		return
+	}
+	cleaned, err := cfg.cleanChirpBody(body.Body)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	if cleaned == chirp.Body {
+		respondWithJSON(w, 200, chirpFromDB(chirp))
+		return
+	}
+
+	// EditChirp saves the current body as a revision before replacing it.
+	var edited database.Chirp
+	err = cfg.inTx(r.Context(), func(q *database.Queries) error {
+		editing := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+		if err := cfg.checkDuplicateChirp(r.Context(), q, chirp.UserID, cleaned, editing); err != nil {
+			return err
+		}
+		edited, err = q.EditChirp(r.Context(), database.EditChirpParams{
+			ID:   chirp.ID,
+			Body: cleaned,
+		})
+		return err
+	})
+	if errors.Is(err, errDuplicateChirp) {
+		respondDuplicateChirp(w)
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Could not edit chirp")
+		return
+	}
+	respondWithJSON(w, 200, chirpFromDB(edited))
+}
+
+// GET /api/chirps/{chirpID}/revisions
+func (cfg *apiConfig) handlerGetRevisions(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 404, "No tiene el formato correcto")
+		return
+	}
+	_, err = cfg.db.OneChirps(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+	rows, err := cfg.db.GetChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
+	}
+	revisions := make([]ChirpRevision, len(rows))
+	for i, rev := range rows {
+		revisions[i] = ChirpRevision{
+			ID:        rev.ID,
+			ChirpID:   rev.ChirpID,
+			Body:      rev.Body,
+			CreatedAt: rev.CreatedAt,
+		}
+	}
+	respondWithJSON(w, 200, revisions)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/auth"
+	"github.com/SoulOppen/chirpy_go_server/internal/database"
+	"github.com/google/uuid"
+)
+
+// POST /api/chirps/{chirpID}/likes
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, chirpID, ok := cfg.likeTarget(w, r)
+	if !ok {
+		return
+	}
+	_, err := cfg.db.LikeChirp(r.Context(), database.LikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Could not like chirp")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// DELETE /api/chirps/{chirpID}/likes
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, chirpID, ok := cfg.likeTarget(w, r)
+	if !ok {
+		return
+	}
+	_, err := cfg.db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Could not unlike chirp")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// likeTarget checks the chirp in the path exists.
+func (cfg *apiConfig) likeTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID := auth.MustUserID(r.Context())
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "id is not uuid")
+		return uuid.Nil, uuid.Nil, false
+	}
+	_, err = cfg.db.OneChirps(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, chirpID, true
+}
+
+// viewerID returns the caller's user ID on routes behind OptionalAuth, or
+// null for anonymous readers.
+func (cfg *apiConfig) viewerID(r *http.Request) uuid.NullUUID {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	return uuid.NullUUID{UUID: userID, Valid: ok}
+}
+
+// likeStats loads like counts for a batch of chirps with a single query.
+// Chirps nobody has liked are absent from the map.
+func (cfg *apiConfig) likeStats(ctx context.Context, ids []uuid.UUID, viewer uuid.NullUUID) (map[uuid.UUID]database.GetLikeStatsRow, error) {
+	stats := make(map[uuid.UUID]database.GetLikeStatsRow, len(ids))
+	if len(ids) == 0 {
+		return stats, nil
+	}
+	rows, err := cfg.db.GetLikeStats(ctx, database.GetLikeStatsParams{
+		ViewerID: viewer,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		stats[row.ChirpID] = row
+	}
+	return stats, nil
+}
+
+// attachLikes fills in like_count and liked_by_me on chirps in place.
+func (cfg *apiConfig) attachLikes(ctx context.Context, chirps []*Chirp, viewer uuid.NullUUID) error {
+	ids := make([]uuid.UUID, len(chirps))
+	for i, c := range chirps {
+		ids[i] = c.ID
+	}
+	stats, err := cfg.likeStats(ctx, ids, viewer)
+	if err != nil {
+		return err
+	}
+	for _, c := range chirps {
+		c.LikeCount = stats[c.ID].LikeCount
+		c.LikedByMe = stats[c.ID].LikedByMe
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/auth"
+	"github.com/SoulOppen/chirpy_go_server/internal/database"
+	"github.com/google/uuid"
+)
+
+// POST /api/chirps/{chirpID}/restore
+func (cfg *apiConfig) handlerRestoreChirp(w http.ResponseWriter, r *http.Request) {
+	userID := auth.MustUserID(r.Context())
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "id is not uuid")
+		return
+	}
+
+	chirp, err := cfg.db.GetDeletedChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+	if chirp.UserID != userID {
+		respondWithError(w, 403, "Fail to modify no authorize")
+		return
+	}
+
+	// The window is checked by the database against its own clock, the same
+	// one that stamped deleted_at.
+	restored, err := cfg.db.RestoreChirp(r.Context(), database.RestoreChirpParams{
+		ID:            chirpID,
+		WindowSeconds: cfg.restoreWindow.Seconds(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 410, "Restore window has expired")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Could not restore chirp")
+		return
+	}
+	respondWithJSON(w, 200, chirpFromDB(restored))
+}
+
+// purgeDeletedChirps hard-deletes tombstoned chirps once their restore window
+// has passed. Tombstones that still have replies are kept as thread
+// placeholders.
+func (cfg *apiConfig) purgeDeletedChirps(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := cfg.db.PurgeDeletedChirps(ctx, cfg.restoreWindow.Seconds())
+			if err != nil {
+				fmt.Printf("Error purging deleted chirps: %v\n", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/database"
+	"github.com/google/uuid"
+)
+
+type searchResult struct {
+	Chirp
+	Rank float64 `json:"rank"`
+	// Snippet is HTML: the chirp text is escaped and the matches are
+	// wrapped in <mark> tags, so it is safe to render as is.
+	Snippet string `json:"snippet"`
+}
+
+type searchPage struct {
+	Results    []searchResult `json:"results"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// GET /api/chirps/search
+func (cfg *apiConfig) handlerSearchChirps(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		respondWithError(w, 400, "q is required")
+		return
+	}
+
+	var authorID uuid.NullUUID
+	if s := strings.TrimSpace(r.URL.Query().Get("author_id")); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, 400, "author_id is not uuid")
+			return
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	scope := newPageScope("rank", "q="+q,
+		filterParam("author_id", authorID.UUID.String(), authorID.Valid),
+	)
+	limit, cursor, err := parsePageParams(r.URL.Query(), scope)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	// Search results are ranked, so they can only be paged forward.
+	if cursor != nil && cursor.Direction != cursorNext {
+		respondWithError(w, 400, "invalid cursor")
+		return
+	}
+
+	params := database.SearchChirpsParams{
+		Query:     q,
+		AuthorID:  authorID,
+		PageLimit: limit + 1,
+	}
+	if cursor != nil {
+		params.CursorRank = sql.NullFloat64{Float64: cursor.Rank, Valid: true}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+	rows, err := cfg.db.SearchChirps(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
+	}
+
+	page := searchPage{Results: make([]searchResult, 0, len(rows))}
+	for _, row := range rows {
+		page.Results = append(page.Results, searchResult{
+			Chirp: chirpFromDB(database.Chirp{
+				ID:        row.ID,
+				CreatedAt: row.CreatedAt,
+				UpdatedAt: row.UpdatedAt,
+				Body:      row.Body,
+				UserID:    row.UserID,
+				InReplyTo: row.InReplyTo,
+				EditedAt:  row.EditedAt,
+			}),
+			Rank:    row.Rank,
+			Snippet: row.Snippet,
+		})
+	}
+	if len(page.Results) > int(limit) {
+		page.Results = page.Results[:limit]
+		last := page.Results[len(page.Results)-1]
+		next := scope.cursor(last.CreatedAt, last.ID, cursorNext)
+		next.Rank = last.Rank
+		page.NextCursor = encodeCursor(next)
+	}
+	refs := make([]*Chirp, len(page.Results))
+	for i := range page.Results {
+		refs[i] = &page.Results[i].Chirp
+	}
+	if err := cfg.attachLikes(r.Context(), refs, cfg.viewerID(r)); err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
+	}
+
+	respondWithJSON(w, 200, page)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type threadChirp struct {
+	ID         uuid.UUID      `json:"id"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	Body       string         `json:"body"`
+	UserId     *uuid.UUID     `json:"user_id"`
+	InReplyTo  *uuid.UUID     `json:"in_reply_to,omitempty"`
+	Deleted    bool           `json:"deleted"`
+	Edited     bool           `json:"edited"`
+	Depth      int32          `json:"depth"`
+	ReplyCount int64          `json:"reply_count"`
+	LikeCount  int64          `json:"like_count"`
+	LikedByMe  bool           `json:"liked_by_me"`
+	Replies    []*threadChirp `json:"replies"`
+}
+
+// GET /api/chirps/{chirpID}/thread
+func (cfg *apiConfig) handlerGetThread(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 404, "No tiene el formato correcto")
+		return
+	}
+	rootID, err := cfg.db.GetThreadRootID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+	rows, err := cfg.db.GetThread(r.Context(), rootID)
+	if err != nil || len(rows) == 0 {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	likes, err := cfg.likeStats(r.Context(), ids, cfg.viewerID(r))
+	if err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
+	}
+
+	// Rows come ordered by depth, so every parent is seen before its replies.
+	nodes := make(map[uuid.UUID]*threadChirp, len(rows))
+	var root *threadChirp
+	for _, row := range rows {
+		node := &threadChirp{
+			ID:         row.ID,
+			CreatedAt:  row.CreatedAt,
+			UpdatedAt:  row.UpdatedAt,
+			Deleted:    row.Deleted,
+			Edited:     row.EditedAt.Valid,
+			Depth:      row.Depth,
+			ReplyCount: row.ReplyCount,
+			LikeCount:  likes[row.ID].LikeCount,
+			LikedByMe:  likes[row.ID].LikedByMe,
+			Replies:    []*threadChirp{},
+		}
+		if !row.Deleted {
+			node.Body = row.Body
+			node.UserId = &row.UserID
+		}
+		nodes[row.ID] = node
+		if root == nil {
+			root = node
+			continue
+		}
+		node.InReplyTo = &row.InReplyTo.UUID
+		if parent, ok := nodes[row.InReplyTo.UUID]; ok {
+			parent.Replies = append(parent.Replies, node)
+		}
+	}
+
+	if !pruneDeleted(root) {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+	respondWithJSON(w, 200, root)
+}
+
+// pruneDeleted drops deleted chirps with nothing left below them, since they
+// are only kept as placeholders for their replies. It reports whether node
+// itself should be kept.
+func pruneDeleted(node *threadChirp) bool {
+	kept := node.Replies[:0]
+	for _, reply := range node.Replies {
+		if pruneDeleted(reply) {
+			kept = append(kept, reply)
+		}
+	}
+	node.Replies = kept
+	return !node.Deleted || len(node.Replies) > 0
+}
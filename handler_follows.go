@@ -0,0 +1,179 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/auth"
+	"github.com/SoulOppen/chirpy_go_server/internal/database"
+	"github.com/google/uuid"
+)
+
+// POST /api/users/{userID}/follow
+func (cfg *apiConfig) handlerFollow(w http.ResponseWriter, r *http.Request) {
+	followerID, followeeID, ok := cfg.followTarget(w, r)
+	if !ok {
+		return
+	}
+	if followerID == followeeID {
+		respondWithError(w, 400, "Can't follow yourself")
+		return
+	}
+	_, err := cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Could not follow user")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// DELETE /api/users/{userID}/follow
+func (cfg *apiConfig) handlerUnfollow(w http.ResponseWriter, r *http.Request) {
+	followerID, followeeID, ok := cfg.followTarget(w, r)
+	if !ok {
+		return
+	}
+	_, err := cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Could not unfollow user")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// followTarget checks the user in the path exists.
+func (cfg *apiConfig) followTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID := auth.MustUserID(r.Context())
+	targetID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "id is not uuid")
+		return uuid.Nil, uuid.Nil, false
+	}
+	if _, err := cfg.db.GetUserByID(r.Context(), targetID); err != nil {
+		respondWithError(w, 404, "User not found")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, targetID, true
+}
+
+// GET /api/users/{userID}/followers
+func (cfg *apiConfig) handlerGetFollowers(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "id is not uuid")
+		return
+	}
+	rows, err := cfg.db.GetFollowers(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
+	}
+	users := make([]PublicUser, len(rows))
+	for i, u := range rows {
+		users[i] = userFromFollowRow(u)
+	}
+	respondWithJSON(w, 200, users)
+}
+
+// GET /api/users/{userID}/following
+func (cfg *apiConfig) handlerGetFollowing(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "id is not uuid")
+		return
+	}
+	rows, err := cfg.db.GetFollowing(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
+	}
+	users := make([]PublicUser, len(rows))
+	for i, u := range rows {
+		users[i] = userFromFollowRow(database.GetFollowersRow(u))
+	}
+	respondWithJSON(w, 200, users)
+}
+
+// PublicUser is what anyone may see of another user. It leaves out the
+// email address, which only the user themselves gets back.
+type PublicUser struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+}
+
+func userFromFollowRow(u database.GetFollowersRow) PublicUser {
+	return PublicUser{
+		ID:             u.ID,
+		CreatedAt:      u.CreatedAt,
+		IsChirpyRed:    u.IsChirpyRed.Bool,
+		FollowerCount:  u.FollowerCount,
+		FollowingCount: u.FollowingCount,
+	}
+}
+
+// GET /api/timeline
+func (cfg *apiConfig) handlerTimeline(w http.ResponseWriter, r *http.Request) {
+	userID := auth.MustUserID(r.Context())
+
+	// The timeline has one fixed order, but a cursor from someone else's
+	// timeline still doesn't belong here.
+	scope := newPageScope("desc", "timeline="+userID.String())
+	limit, cursor, err := parsePageParams(r.URL.Query(), scope)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	var cursorCreatedAt sql.NullTime
+	var cursorID uuid.NullUUID
+	if cursor != nil {
+		cursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		cursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	// Newest first; paging backwards walks oldest first and newChirpPage
+	// flips it back.
+	var dbChirps []database.Chirp
+	if cursor != nil && cursor.Direction == cursorPrev {
+		dbChirps, err = cfg.db.GetTimelinePageAsc(r.Context(), database.GetTimelinePageAscParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       limit + 1,
+		})
+	} else {
+		dbChirps, err = cfg.db.GetTimelinePageDesc(r.Context(), database.GetTimelinePageDescParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       limit + 1,
+		})
+	}
+	if err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
+	}
+
+	chirps := make([]Chirp, len(dbChirps))
+	refs := make([]*Chirp, len(dbChirps))
+	for i, c := range dbChirps {
+		chirps[i] = chirpFromDB(c)
+		refs[i] = &chirps[i]
+	}
+	viewer := uuid.NullUUID{UUID: userID, Valid: true}
+	if err := cfg.attachLikes(r.Context(), refs, viewer); err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
+	}
+
+	respondWithJSON(w, 200, newChirpPage(chirps, limit, cursor, scope))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// jwksMaxAge is how long clients may cache the JWKS. New keys are published
+// for this long before they sign, see auth.KeySet.SetPublishDelay.
+const jwksMaxAge = 5 * time.Minute
+
+// GET /.well-known/jwks.json
+func (cfg *apiConfig) handlerJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge.Seconds())))
+	respondWithJSON(w, 200, cfg.jwtKeys.JWKS())
+}
+
+// POST /admin/jwks/rotate
+//
+// The new key is published at once and signs after jwksMaxAge.
+func (cfg *apiConfig) handlerRotateKeys(w http.ResponseWriter, r *http.Request) {
+	kid, err := cfg.jwtKeys.Rotate()
+	if err != nil {
+		respondWithError(w, 500, "Could not rotate keys")
+		return
+	}
+	respondWithJSON(w, 200, struct {
+		Kid string `json:"kid"`
+	}{
+		Kid: kid,
+	})
+}
+
+// keyRetention is how long a retired key keeps verifying: long enough for
+// every token it signed to expire.
+func (cfg *apiConfig) keyRetention() time.Duration {
+	return cfg.accessTokenTTL
+}
+
+// rotateSigningKeys switches to a fresh signing key every interval and drops
+// retired keys once no live token can still depend on them. Reloading the
+// key directory first keeps instances that share it in step: the key's age
+// decides, so only one of them rotates, and keys the others rotated in are
+// trusted before anything is pruned.
+func (cfg *apiConfig) rotateSigningKeys(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(min(every, time.Minute))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cfg.jwtKeys.Reload(); err != nil {
+				fmt.Printf("Error reloading JWT keys: %v\n", err)
+				continue
+			}
+			if cfg.jwtKeys.NewestKeyAge() >= every {
+				if _, err := cfg.jwtKeys.Rotate(); err != nil {
+					fmt.Printf("Error rotating JWT keys: %v\n", err)
+				}
+			}
+			cfg.jwtKeys.Prune(cfg.keyRetention())
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/loginguard"
+)
+
+// Per-email limits protect one account from a distributed guesser; per-IP
+// limits stop one client spraying many accounts. IPs get more room because
+// a NAT can put many users behind one address.
+var (
+	emailLoginPolicy = loginguard.Policy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockoutAfter: 10,
+		LockoutFor:   15 * time.Minute,
+		ResetAfter:   time.Hour,
+	}
+	ipLoginPolicy = loginguard.Policy{
+		FreeAttempts: 20,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockoutAfter: 100,
+		LockoutFor:   15 * time.Minute,
+		ResetAfter:   time.Hour,
+	}
+)
+
+func emailGuardKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipGuardKey(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// reserveLoginAttempt counts an attempt for this email from this address
+// before the password or code is checked, so parallel guesses can't slip
+// past the limits. A non-zero wait means the attempt was refused and not
+// counted.
+func (cfg *apiConfig) reserveLoginAttempt(ctx context.Context, email string, r *http.Request) (time.Duration, error) {
+	byIP, err := cfg.ipGuard.Attempt(ctx, ipGuardKey(r))
+	if err != nil || byIP > 0 {
+		return byIP, err
+	}
+	byEmail, err := cfg.emailGuard.Attempt(ctx, emailGuardKey(email))
+	if err != nil || byEmail > 0 {
+		if rerr := cfg.ipGuard.Refund(ctx, ipGuardKey(r)); rerr != nil && err == nil {
+			err = rerr
+		}
+		return byEmail, err
+	}
+	return 0, nil
+}
+
+// loginSucceeded clears the account's failures. The address only gets this
+// attempt back, so one valid account can't be used to reset a spraying
+// client.
+func (cfg *apiConfig) loginSucceeded(ctx context.Context, email string, r *http.Request) error {
+	if err := cfg.emailGuard.Succeed(ctx, emailGuardKey(email)); err != nil {
+		return err
+	}
+	return cfg.ipGuard.Refund(ctx, ipGuardKey(r))
+}
+
+func respondTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	respondWithError(w, 429, "Too many login attempts")
+}
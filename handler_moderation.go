@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/moderation"
+)
+
+type moderationTerm struct {
+	Term string `json:"term"`
+}
+
+// GET /admin/moderation/terms
+func (cfg *apiConfig) handlerListTerms(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, 200, cfg.moderation.Terms())
+}
+
+// POST /admin/moderation/terms
+func (cfg *apiConfig) handlerAddTerm(w http.ResponseWriter, r *http.Request) {
+	var body moderationTerm
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&body); err != nil {
+		respondWithError(w, 400, "Invalid JSON body")
+		return
+	}
+	term, err := moderation.NormalizeTerm(body.Term)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	if err := cfg.db.AddBannedWord(r.Context(), term); err != nil {
+		respondWithError(w, 500, "Could not save term")
+		return
+	}
+	cfg.moderation.Add(term)
+	respondWithJSON(w, 201, moderationTerm{Term: term})
+}
+
+// DELETE /admin/moderation/terms/{term}
+func (cfg *apiConfig) handlerRemoveTerm(w http.ResponseWriter, r *http.Request) {
+	term, err := moderation.NormalizeTerm(r.PathValue("term"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	n, err := cfg.db.RemoveBannedWord(r.Context(), term)
+	if err != nil {
+		respondWithError(w, 500, "Could not remove term")
+		return
+	}
+	removed := cfg.moderation.Remove(term)
+	if n == 0 && !removed {
+		respondWithError(w, 404, "Term not found")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// reloadBannedWords refreshes the filter from the database every interval,
+// so terms added or removed through another instance take effect here too.
+func (cfg *apiConfig) reloadBannedWords(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			terms, err := cfg.db.ListBannedWords(ctx)
+			if err != nil {
+				fmt.Printf("Error reloading banned words: %v\n", err)
+				continue
+			}
+			cfg.moderation.Replace(terms)
+		}
+	}
+}
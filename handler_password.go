@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/auth"
+	"github.com/SoulOppen/chirpy_go_server/internal/database"
+	"github.com/SoulOppen/chirpy_go_server/internal/loginguard"
+	"github.com/SoulOppen/chirpy_go_server/internal/mailer"
+)
+
+// Reset requests send mail to someone else's inbox, so they are limited per
+// address and per client, and only a few are worked on at once.
+var (
+	resetEmailPolicy = loginguard.Policy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Minute,
+		MaxDelay:     30 * time.Minute,
+		ResetAfter:   time.Hour,
+	}
+	resetIPPolicy = loginguard.Policy{
+		FreeAttempts: 10,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Minute,
+		ResetAfter:   time.Hour,
+	}
+)
+
+const maxPendingResets = 16
+
+// POST /api/password/forgot
+func (cfg *apiConfig) handlerForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, 400, "Invalid JSON body")
+		return
+	}
+	email := strings.TrimSpace(params.Email)
+	if email == "" {
+		respondWithError(w, 400, "email is required")
+		return
+	}
+	// The limits apply whether or not the account exists, so a 429 says
+	// nothing about who is registered.
+	wait, err := cfg.resetIPGuard.Attempt(r.Context(), "reset:ip:"+clientIP(r))
+	if err == nil && wait == 0 {
+		wait, err = cfg.resetEmailGuard.Attempt(r.Context(), "reset:email:"+strings.ToLower(email))
+	}
+	if err != nil {
+		respondWithError(w, 500, "error")
+		return
+	}
+	if wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return
+	}
+	// Everything else happens after the response, so neither its content
+	// nor its timing shows whether the address belongs to an account. When
+	// too many are already in flight the request is dropped.
+	select {
+	case cfg.resetSlots <- struct{}{}:
+		go func() {
+			defer func() { <-cfg.resetSlots }()
+			cfg.sendPasswordReset(email)
+		}()
+	default:
+		fmt.Printf("Dropping password reset for %s: too many in flight\n", email)
+	}
+	w.WriteHeader(202)
+}
+
+// sendPasswordReset mails a reset token if email belongs to an account.
+func (cfg *apiConfig) sendPasswordReset(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	user, err := cfg.db.ReturnUserNotPassword(ctx, email)
+	if err != nil {
+		return
+	}
+	token, err := auth.MakeToken()
+	if err != nil {
+		fmt.Printf("Error creating reset token: %v\n", err)
+		return
+	}
+	// Only the newest token works, so the table holds at most one live
+	// token per user.
+	err = cfg.inTx(ctx, func(q *database.Queries) error {
+		if err := q.InvalidatePasswordResetTokens(ctx, user.ID); err != nil {
+			return err
+		}
+		return q.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+			TokenHash: auth.HashToken(token),
+			UserID:    user.ID,
+			ExpiresAt: time.Now().Add(cfg.resetTokenTTL),
+		})
+	})
+	if err != nil {
+		fmt.Printf("Error creating reset token: %v\n", err)
+		return
+	}
+	cfg.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password for this account.\n\n"+
+				"Reset token: %s\n\n"+
+				"Send it to POST /api/password/reset with your new password. "+
+				"It can be used once and expires in %s. If you didn't ask for this, ignore this email.\n",
+			token, cfg.resetTokenTTL),
+	})
+}
+
+// POST /api/password/reset
+func (cfg *apiConfig) handlerResetPassword(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, 400, "Invalid JSON body")
+		return
+	}
+	if params.Token == "" || params.Password == "" {
+		respondWithError(w, 400, "token and password are required")
+		return
+	}
+	hashed, err := auth.HashPassword(params.Password)
+	if err != nil {
+		respondWithError(w, 500, "Could not hash password")
+		return
+	}
+	// The token is spent and the password changed in one statement, so a
+	// token can't be used twice even by concurrent requests.
+	userID, err := cfg.db.ResetPassword(r.Context(), database.ResetPasswordParams{
+		TokenHash:      auth.HashToken(params.Token),
+		HashedPassword: hashed,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Invalid or expired token")
+		return
+	}
+	if err := cfg.db.InvalidatePasswordResetTokens(r.Context(), userID); err != nil {
+		respondWithError(w, 500, "Could not invalidate reset tokens")
+		return
+	}
+	// Whoever knew the old password may still hold a session.
+	if _, err := cfg.db.RevokeUserRefreshTokens(r.Context(), userID); err != nil {
+		respondWithError(w, 500, "Could not revoke sessions")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// sendMail delivers msg in the background, logging failures since there is
+// no request left to report them to.
+func (cfg *apiConfig) sendMail(msg mailer.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := cfg.mailer.Send(ctx, msg); err != nil {
+		fmt.Printf("Error sending mail to %s: %v\n", msg.To, err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/auth"
+	"github.com/SoulOppen/chirpy_go_server/internal/database"
+	"github.com/google/uuid"
+)
+
+// Session is one signed-in device. Its ID is the refresh token family, which
+// stays the same while the token itself rotates.
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	SignedInAt time.Time `json:"signed_in_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// GET /api/sessions
+func (cfg *apiConfig) handlerListSessions(w http.ResponseWriter, r *http.Request) {
+	userID := auth.MustUserID(r.Context())
+	rows, err := cfg.db.ListSessions(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "Could not list sessions")
+		return
+	}
+	sessions := make([]Session, 0, len(rows))
+	for _, row := range rows {
+		sessions = append(sessions, Session{
+			ID:         row.FamilyID,
+			UserAgent:  row.UserAgent,
+			IPAddress:  row.IpAddress,
+			SignedInAt: row.SignedInAt,
+			LastUsedAt: row.LastUsedAt,
+			ExpiresAt:  row.ExpiresAt.Time,
+		})
+	}
+	respondWithJSON(w, 200, sessions)
+}
+
+// DELETE /api/sessions/{sessionID}
+func (cfg *apiConfig) handlerRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID := auth.MustUserID(r.Context())
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, 400, "id is not uuid")
+		return
+	}
+	// Only the live token of a family can be refreshed, so revoking it ends
+	// the session; a later attempt with an older token revokes the rest.
+	rt, err := cfg.db.GetActiveSession(r.Context(), database.GetActiveSessionParams{
+		FamilyID: sessionID,
+		UserID:   userID,
+	})
+	if err != nil {
+		respondWithError(w, 404, "Session not found")
+		return
+	}
+	if _, err := cfg.db.UpdateRefreshToken(r.Context(), rt.TokenHash); err != nil {
+		respondWithError(w, 500, "Could not revoke session")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// POST /api/sessions/revoke-all
+//
+// Access tokens already handed out stay valid until they expire.
+func (cfg *apiConfig) handlerRevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID := auth.MustUserID(r.Context())
+	if _, err := cfg.db.RevokeUserRefreshTokens(r.Context(), userID); err != nil {
+		respondWithError(w, 500, "Could not revoke sessions")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// clientIP is the address the request came from, without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
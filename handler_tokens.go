@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/auth"
+	"github.com/SoulOppen/chirpy_go_server/internal/database"
+	"github.com/google/uuid"
+)
+
+// maxTokenLifetime is the longest expires_in_seconds a personal access
+// token can be created with. Leave it out for a token that never expires.
+const maxTokenLifetime = 365 * 24 * time.Hour
+
+// PersonalToken is a personal access token as listed to its owner. The
+// token itself is only shown once, when it is created.
+type PersonalToken struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	Token     string     `json:"token,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	// LastUsedAt is updated at most once a minute, so it can lag behind the
+	// latest use by up to that much.
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func personalTokenFromDB(t database.PersonalAccessToken) PersonalToken {
+	pt := PersonalToken{
+		ID:        t.ID,
+		Name:      t.Name,
+		Scopes:    t.Scopes,
+		CreatedAt: t.CreatedAt,
+	}
+	if t.ExpiresAt.Valid {
+		pt.ExpiresAt = &t.ExpiresAt.Time
+	}
+	if t.LastUsedAt.Valid {
+		pt.LastUsedAt = &t.LastUsedAt.Time
+	}
+	return pt
+}
+
+// Verify accepts personal access tokens as well as access tokens, so
+// apiConfig can be handed to auth.RequireAuth.
+func (cfg *apiConfig) Verify(ctx context.Context, token string) (*auth.Claims, error) {
+	if !auth.IsPersonalToken(token) {
+		return cfg.jwtKeys.Verify(ctx, token)
+	}
+	row, err := cfg.db.GetPersonalTokenForAuth(ctx, auth.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.db.TouchPersonalToken(ctx, row.ID); err != nil {
+		fmt.Printf("Error recording token use: %v\n", err)
+	}
+	claims := &auth.Claims{Role: row.Role, Scoped: true, Scopes: row.Scopes}
+	claims.Subject = row.UserID.String()
+	return claims, nil
+}
+
+// POST /api/tokens
+func (cfg *apiConfig) handlerCreateToken(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name             string   `json:"name"`
+		Scopes           []string `json:"scopes"`
+		ExpiresInSeconds int      `json:"expires_in_seconds"`
+	}
+	userID := auth.MustUserID(r.Context())
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, 400, "Invalid JSON")
+		return
+	}
+	if params.Name == "" || len(params.Name) > 100 {
+		respondWithError(w, 400, "name must be 1 to 100 characters")
+		return
+	}
+	if len(params.Scopes) == 0 {
+		respondWithError(w, 400, "At least one scope is required")
+		return
+	}
+	for _, scope := range params.Scopes {
+		if !auth.ValidScope(scope) {
+			respondWithError(w, 400, "Unknown scope "+scope)
+			return
+		}
+	}
+	slices.Sort(params.Scopes)
+	params.Scopes = slices.Compact(params.Scopes)
+	if params.ExpiresInSeconds < 0 {
+		respondWithError(w, 400, "expires_in_seconds must not be negative")
+		return
+	}
+	if params.ExpiresInSeconds > int(maxTokenLifetime/time.Second) {
+		respondWithError(w, 400, fmt.Sprintf("expires_in_seconds must be at most %d", int(maxTokenLifetime/time.Second)))
+		return
+	}
+	var expiresAt sql.NullTime
+	if params.ExpiresInSeconds > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().Add(time.Duration(params.ExpiresInSeconds) * time.Second), Valid: true}
+	}
+
+	token, err := auth.MakePersonalToken()
+	if err != nil {
+		respondWithError(w, 500, "Could not create token")
+		return
+	}
+	row, err := cfg.db.CreatePersonalToken(r.Context(), database.CreatePersonalTokenParams{
+		UserID:    userID,
+		Name:      params.Name,
+		TokenHash: auth.HashToken(token),
+		Scopes:    params.Scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Could not create token")
+		return
+	}
+	pt := personalTokenFromDB(row)
+	pt.Token = token
+	respondWithJSON(w, 201, pt)
+}
+
+// GET /api/tokens
+func (cfg *apiConfig) handlerListTokens(w http.ResponseWriter, r *http.Request) {
+	userID := auth.MustUserID(r.Context())
+	rows, err := cfg.db.ListPersonalTokens(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "Could not list tokens")
+		return
+	}
+	tokens := make([]PersonalToken, 0, len(rows))
+	for _, row := range rows {
+		tokens = append(tokens, personalTokenFromDB(row))
+	}
+	respondWithJSON(w, 200, tokens)
+}
+
+// DELETE /api/tokens/{tokenID}
+func (cfg *apiConfig) handlerRevokeToken(w http.ResponseWriter, r *http.Request) {
+	userID := auth.MustUserID(r.Context())
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, 400, "id is not uuid")
+		return
+	}
+	n, err := cfg.db.RevokePersonalToken(r.Context(), database.RevokePersonalTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Could not revoke token")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, 404, "Token not found")
+		return
+	}
+	w.WriteHeader(204)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/auth"
+	"github.com/SoulOppen/chirpy_go_server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer = "Chirpy"
+	// loginChallengeTTL is how long a user has to type their code after the
+	// password step.
+	loginChallengeTTL    = 5 * time.Minute
+	maxChallengeAttempts = 5
+	recoveryCodeCount    = 10
+)
+
+// POST /api/2fa/enroll
+func (cfg *apiConfig) handlerEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID := auth.MustUserID(r.Context())
+	if cfg.totpBox == nil {
+		respondWithError(w, 503, "2FA is not configured")
+		return
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, 500, "Could not create secret")
+		return
+	}
+	sealed, err := cfg.totpBox.Seal([]byte(secret))
+	if err != nil {
+		respondWithError(w, 500, "Could not create secret")
+		return
+	}
+	// Enrolling again before confirming replaces the pending secret; an
+	// enabled one is left alone.
+	n, err := cfg.db.UpsertPendingTOTP(r.Context(), database.UpsertPendingTOTPParams{
+		UserID:          userID,
+		SecretEncrypted: sealed,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, 409, "2FA already enabled")
+		return
+	}
+	respondWithJSON(w, 200, struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}{
+		Secret:     secret,
+		OtpauthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+var errTOTPAlreadyEnabled = errors.New("2FA already enabled")
+
+// POST /api/2fa/confirm
+func (cfg *apiConfig) handlerConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID := auth.MustUserID(r.Context())
+	if cfg.totpBox == nil {
+		respondWithError(w, 503, "2FA is not configured")
+		return
+	}
+	var params struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, 400, "Invalid JSON body")
+		return
+	}
+	totp, err := cfg.db.GetUserTOTP(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 404, "2FA enrollment not started")
+		return
+	}
+	if totp.EnabledAt.Valid {
+		respondWithError(w, 409, "2FA already enabled")
+		return
+	}
+	step, ok := cfg.checkTOTP(totp, params.Code)
+	if !ok {
+		respondWithError(w, 401, "Invalid code")
+		return
+	}
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(w, 500, "Could not create recovery codes")
+		return
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = auth.HashToken(c)
+	}
+	// 2FA is only switched on together with the codes the user is about to
+	// be shown.
+	err = cfg.inTx(r.Context(), func(q *database.Queries) error {
+		n, err := q.EnableTOTP(r.Context(), database.EnableTOTPParams{
+			Step:   step,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errTOTPAlreadyEnabled
+		}
+		if err := q.DeleteRecoveryCodes(r.Context(), userID); err != nil {
+			return err
+		}
+		return q.CreateRecoveryCodes(r.Context(), database.CreateRecoveryCodesParams{
+			UserID:     userID,
+			CodeHashes: hashes,
+		})
+	})
+	if errors.Is(err, errTOTPAlreadyEnabled) {
+		respondWithError(w, 409, "2FA already enabled")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
+	}
+	// The codes are only ever shown here.
+	respondWithJSON(w, 200, struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{RecoveryCodes: codes})
+}
+
+// POST /api/login/2fa
+func (cfg *apiConfig) handlerLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		ChallengeToken   string `json:"challenge_token"`
+		Code             string `json:"code"`
+		ExpiresInSeconds int    `json:"expires_in_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, 400, "Invalid JSON body")
+		return
+	}
+	challengeHash := auth.HashToken(params.ChallengeToken)
+	challenge, err := cfg.db.GetLoginChallenge(r.Context(), challengeHash)
+	if err != nil || challenge.Attempts >= maxChallengeAttempts {
+		respondWithError(w, 401, "Invalid or expired challenge")
+		return
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), challenge.UserID)
+	if err != nil {
+		respondWithError(w, 401, "User not found")
+		return
+	}
+	// Wrong codes count against the account like wrong passwords, so new
+	// challenges don't buy an attacker fresh guesses.
+	wait, err := cfg.reserveLoginAttempt(r.Context(), user.Email, r)
+	if err != nil {
+		respondWithError(w, 500, "error")
+		return
+	}
+	if wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return
+	}
+	ok, err := cfg.checkSecondFactor(r.Context(), challenge.UserID, params.Code)
+	if err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
+	}
+	if !ok {
+		if err := cfg.db.FailLoginChallenge(r.Context(), challengeHash); err != nil {
+			respondWithError(w, 500, "Fail to connect to DB")
+			return
+		}
+		respondWithError(w, 401, "Invalid code")
+		return
+	}
+	// Consuming the challenge is what makes it single use; a concurrent
+	// request with the same challenge gets no row back.
+	if _, err := cfg.db.ConsumeLoginChallenge(r.Context(), challengeHash); err != nil {
+		respondWithError(w, 401, "Invalid or expired challenge")
+		return
+	}
+	if err := cfg.loginSucceeded(r.Context(), user.Email, r); err != nil {
+		respondWithError(w, 500, "error")
+		return
+	}
+	cfg.completeLogin(w, r, userFromDB(user), params.ExpiresInSeconds)
+}
+
+// twoFactorEnabled reports whether login needs a code as well as the
+// password.
+func (cfg *apiConfig) twoFactorEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	totp, err := cfg.db.GetUserTOTP(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return totp.EnabledAt.Valid, nil
+}
+
+// startLoginChallenge answers a correct password from a 2FA user with a
+// challenge token instead of a session.
+func (cfg *apiConfig) startLoginChallenge(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	token, err := auth.MakeToken()
+	if err != nil {
+		respondWithError(w, 500, "Could not create challenge")
+		return
+	}
+	expiresAt := time.Now().Add(loginChallengeTTL)
+	err = cfg.db.CreateLoginChallenge(r.Context(), database.CreateLoginChallengeParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Could not create challenge")
+		return
+	}
+	respondWithJSON(w, 200, struct {
+		TwoFactorRequired bool      `json:"two_factor_required"`
+		ChallengeToken    string    `json:"challenge_token"`
+		ExpiresAt         time.Time `json:"expires_at"`
+	}{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         expiresAt,
+	})
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused
+// recovery code. Both are spent on success so neither can be replayed.
+func (cfg *apiConfig) checkSecondFactor(ctx context.Context, userID uuid.UUID, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return false, nil
+	}
+	if cfg.totpBox == nil {
+		return false, errors.New("2FA is not configured")
+	}
+	totp, err := cfg.db.GetUserTOTP(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if step, ok := cfg.checkTOTP(totp, code); ok {
+		n, err := cfg.db.UseTOTPStep(ctx, database.UseTOTPStepParams{
+			Step:   step,
+			UserID: userID,
+		})
+		return n == 1, err
+	}
+	n, err := cfg.db.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+	})
+	return n == 1, err
+}
+
+func (cfg *apiConfig) checkTOTP(totp database.UserTotp, code string) (int64, bool) {
+	secret, err := cfg.totpBox.Open(totp.SecretEncrypted)
+	if err != nil {
+		return 0, false
+	}
+	return auth.ValidateTOTP(string(secret), code, time.Now())
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/auth"
+	"github.com/SoulOppen/chirpy_go_server/internal/database"
+	"github.com/SoulOppen/chirpy_go_server/internal/mailer"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// GET /api/verify?token=...
+func (cfg *apiConfig) handlerVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSpace(r.URL.Query().Get("token"))
+	if token == "" {
+		respondWithError(w, 400, "token is required")
+		return
+	}
+	// Spending the token and switching the address happen together, so a
+	// pending email change only takes effect once it is proven.
+	user, err := cfg.db.VerifyEmail(r.Context(), auth.HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 400, "Invalid or expired token")
+		return
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		// The address was taken by another account after the link was sent.
+		respondWithError(w, 409, "Email already in use")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Could not verify email")
+		return
+	}
+	if err := cfg.db.InvalidateEmailVerificationTokens(r.Context(), user.ID); err != nil {
+		respondWithError(w, 500, "Could not invalidate verification tokens")
+		return
+	}
+	respondWithJSON(w, 200, userFromDB(user))
+}
+
+// POST /api/verify/resend
+func (cfg *apiConfig) handlerResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID := auth.MustUserID(r.Context())
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		respondWithError(w, 409, "Email already verified")
+		return
+	}
+	if err := cfg.sendVerification(r.Context(), user.ID, user.Email); err != nil {
+		respondWithError(w, 500, "Could not create verification token")
+		return
+	}
+	w.WriteHeader(202)
+}
+
+// sendVerification mails a link that, once followed, sets the user's email
+// to email and marks it verified. Links sent earlier stop working, so an
+// address the user has since corrected can't take over the account.
+func (cfg *apiConfig) sendVerification(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := auth.MakeToken()
+	if err != nil {
+		return err
+	}
+	err = cfg.inTx(ctx, func(q *database.Queries) error {
+		if err := q.InvalidateEmailVerificationTokens(ctx, userID); err != nil {
+			return err
+		}
+		return q.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+			TokenHash: auth.HashToken(token),
+			UserID:    userID,
+			Email:     email,
+			ExpiresAt: time.Now().Add(cfg.verifyTokenTTL),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	link := cfg.baseURL + "/api/verify?token=" + url.QueryEscape(token)
+	go cfg.sendMail(mailer.Message{
+		To:      email,
+		Subject: "Verify your Chirpy email",
+		Body: fmt.Sprintf(
+			"Confirm this address for your Chirpy account by opening:\n\n%s\n\n"+
+				"The link expires in %s. If you didn't ask for this, ignore this email.\n",
+			link, cfg.verifyTokenTTL),
+	})
+	return nil
+}
+
+// requireVerifiedEmail writes a 403 and returns false when verification is
+// enforced and the user has not verified their address yet.
+func (cfg *apiConfig) requireVerifiedEmail(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	if !cfg.requireVerified {
+		return true
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 401, "User not found")
+		return false
+	}
+	if !user.EmailVerifiedAt.Valid {
+		respondWithErrorCode(w, 403, "email_not_verified", "Verify your email first")
+		return false
+	}
+	return true
+}
+
+// parseEmail accepts a bare address such as "name@example.com", without a
+// display name.
+func parseEmail(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	addr, err := netmail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return "", errors.New("invalid email address")
+	}
+	return s, nil
+}
+
+func userFromDB(u database.User) User {
+	verified := u.EmailVerifiedAt.Valid
+	return User{
+		ID:            u.ID,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+		Email:         u.Email,
+		IsChirpyRed:   u.IsChirpyRed.Bool,
+		EmailVerified: &verified,
+		Role:          u.Role,
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/SoulOppen/chirpy_go_server/internal/database"
+	"github.com/google/uuid"
+)
+
+const polkaProvider = "polka"
+
+// Statuses of a recorded webhook event. Only failed events, and pending ones
+// whose claim is older than webhookClaimLease, are processed again, whether
+// Polka retries them or an admin replays them.
+const (
+	webhookPending   = "pending"
+	webhookProcessed = "processed"
+	webhookIgnored   = "ignored"
+	webhookFailed    = "failed"
+)
+
+var webhookStatuses = []string{webhookPending, webhookProcessed, webhookIgnored, webhookFailed}
+
+// webhookClaimLease is how long a pending event is left to the request
+// processing it. After that the process is assumed to have died and the
+// event can be claimed again.
+const webhookClaimLease = 5 * time.Minute
+
+func webhookStaleBefore() time.Time {
+	return time.Now().Add(-webhookClaimLease)
+}
+
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID string `json:"user_id"`
+	} `json:"data"`
+}
+
+// WebhookEvent is a recorded delivery as shown to admins.
+type WebhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	Attempts    int32           `json:"attempts"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ClaimedAt   time.Time       `json:"claimed_at"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+func webhookEventFromDB(e database.WebhookEvent) WebhookEvent {
+	we := WebhookEvent{
+		ID:         e.ID,
+		Provider:   e.Provider,
+		EventID:    e.EventID,
+		EventType:  e.EventType,
+		Payload:    json.RawMessage(e.Payload),
+		Status:     e.Status,
+		Error:      e.Error.String,
+		Attempts:   e.Attempts,
+		ReceivedAt: e.ReceivedAt,
+		ClaimedAt:  e.ClaimedAt,
+	}
+	if e.ProcessedAt.Valid {
+		we.ProcessedAt = &e.ProcessedAt.Time
+	}
+	return we
+}
+
+// webhookError is a failed event, with the status Polka is answered with.
+type webhookError struct {
+	code int
+	msg  string
+}
+
+func (e *webhookError) Error() string { return e.msg }
+
+// POST /api/polka/webhooks
+//
+// Every delivery is recorded before it is applied. Deliveries of an event
+// that was already handled are acknowledged without doing anything.
+func (cfg *apiConfig) handlerHook(w http.ResponseWriter, r *http.Request) {
+	// The signature covers the exact bytes sent, so read them before decoding.
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		respondWithError(w, 400, "could not read body")
+		return
+	}
+	if err := cfg.polkaWebhooks.Verify(r.Header, body); err != nil {
+		fmt.Printf("Error verifying Polka webhook: %v\n", err)
+		respondWithError(w, 401, "not authorize")
+		return
+	}
+	var param polkaEvent
+	err = json.Unmarshal(body, &param)
+	if err != nil {
+		respondWithError(w, 400, "no se pudo decodificar")
+		return
+	}
+	// Events without an ID are told apart by their content, so a resent
+	// body still counts as the same event.
+	eventID := param.ID
+	if eventID == "" {
+		sum := sha256.Sum256(body)
+		eventID = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	event, err := cfg.db.ClaimWebhookEvent(r.Context(), database.ClaimWebhookEventParams{
+		Provider:    polkaProvider,
+		EventID:     eventID,
+		EventType:   param.Event,
+		Payload:     string(body),
+		StaleBefore: webhookStaleBefore(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(204)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error recording webhook event: %v\n", err)
+		respondWithError(w, 500, "could not record event")
+		return
+	}
+	if _, err := cfg.processWebhookEvent(r.Context(), event); err != nil {
+		var werr *webhookError
+		if errors.As(err, &werr) {
+			respondWithError(w, werr.code, werr.msg)
+			return
+		}
+		respondWithError(w, 500, "could not process event")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// processWebhookEvent applies a claimed event and records the outcome. The
+// returned event is zero if the outcome could not be recorded.
+//
+// Once claimed, the event is seen through even if the sender hangs up;
+// otherwise it would stay pending until the claim goes stale.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, event database.WebhookEvent) (database.WebhookEvent, error) {
+	ctx = context.WithoutCancel(ctx)
+	status := webhookProcessed
+	err := cfg.applyPolkaEvent(ctx, event.Payload)
+	if errors.Is(err, errEventIgnored) {
+		status, err = webhookIgnored, nil
+	}
+	var errMsg sql.NullString
+	if err != nil {
+		status = webhookFailed
+		errMsg = sql.NullString{String: err.Error(), Valid: true}
+	}
+	event, ferr := cfg.db.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+		ID:     event.ID,
+		Status: status,
+		Error:  errMsg,
+	})
+	if ferr != nil {
+		fmt.Printf("Error recording webhook outcome: %v\n", ferr)
+		if err == nil {
+			err = ferr
+		}
+	}
+	return event, err
+}
+
+var errEventIgnored = errors.New("event ignored")
+
+func (cfg *apiConfig) applyPolkaEvent(ctx context.Context, payload string) error {
+	var param polkaEvent
+	if err := json.Unmarshal([]byte(payload), &param); err != nil {
+		return &webhookError{400, "no se pudo decodificar"}
+	}
+	if param.Event != "user.upgraded" {
+		return errEventIgnored
+	}
+	u, err := uuid.Parse(param.Data.UserID)
+	if err != nil {
+		return &webhookError{400, "can't convert id"}
+	}
+	_, err = cfg.db.UpdateUserIsRed(ctx, u)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &webhookError{404, "not user id"}
+	}
+	return err
+}
+
+// GET /admin/webhooks/events?status=failed
+//
+// status=stale lists pending events whose claim has lapsed, which can be
+// replayed like failed ones.
+func (cfg *apiConfig) handlerListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, err := parseLimit(query)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	var status sql.NullString
+	staleOnly := false
+	if s := query.Get("status"); s == "stale" {
+		staleOnly = true
+	} else if slices.Contains(webhookStatuses, s) {
+		status = sql.NullString{String: s, Valid: true}
+	} else if s != "" {
+		respondWithError(w, 400, "status must be pending, processed, ignored, failed or stale")
+		return
+	}
+	rows, err := cfg.db.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+		Status:      status,
+		StaleOnly:   staleOnly,
+		StaleBefore: webhookStaleBefore(),
+		MaxRows:     limit,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Could not list webhook events")
+		return
+	}
+	events := make([]WebhookEvent, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, webhookEventFromDB(row))
+	}
+	respondWithJSON(w, 200, events)
+}
+
+// POST /admin/webhooks/events/{eventID}/replay
+func (cfg *apiConfig) handlerReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, 400, "id is not uuid")
+		return
+	}
+	event, err := cfg.db.ReclaimWebhookEvent(r.Context(), database.ReclaimWebhookEventParams{
+		ID:          eventID,
+		StaleBefore: webhookStaleBefore(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "No failed or stale event with that id")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Could not replay event")
+		return
+	}
+	// Whether it failed again or not, the outcome is in the finished event.
+	finished, _ := cfg.processWebhookEvent(r.Context(), event)
+	if finished.ID == uuid.Nil {
+		respondWithError(w, 500, "Could not record event outcome")
+		return
+	}
+	respondWithJSON(w, 200, webhookEventFromDB(finished))
+}
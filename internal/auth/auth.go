@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"net/http"
@@ -10,64 +11,81 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// HashPassword hashes with argon2id, see Argon2Params. Unlike bcrypt it
+// uses the whole password, however long.
 func HashPassword(password string) (string, error) {
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hashedBytes), nil
+	return hashArgon2(password, passwordParams)
 }
+
+// CheckPasswordHash verifies argon2id hashes and the bcrypt hashes stored
+// before them.
 func CheckPasswordHash(password, hash string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	if err != nil {
-		return err
+	if isBcryptHash(hash) {
+		return checkBcrypt(password, hash)
 	}
-	return nil
+	return checkArgon2(password, hash)
+}
+
+// Claims are the access token claims: the standard ones, with the user in
+// Subject, plus the user's role. Personal access tokens are described with
+// the same type, marked Scoped and limited to Scopes.
+type Claims struct {
+	jwt.RegisteredClaims
+	Role   string   `json:"role,omitempty"`
+	Scoped bool     `json:"-"`
+	Scopes []string `json:"-"`
 }
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	expiresIn = time.Hour * 24
+
+func MakeJWT(userID uuid.UUID, role string, keys *KeySet, expiresIn time.Duration) (string, error) {
 	id := userID.String()
-	claims := jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		Issuer:    "chirpy",
-		Subject:   id,
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "chirpy",
+			Subject:   id,
+		},
+		Role: role,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	s, err := token.SignedString([]byte(tokenSecret))
-	if err != nil {
-		return "", err
-	}
-	return s, nil
+	return keys.sign(claims)
 }
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claims := &jwt.RegisteredClaims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+// ParseJWT verifies an access token and returns its claims.
+func ParseJWT(tokenString string, keys *KeySet) (*Claims, error) {
+	claims := &Claims{}
 
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrTokenSignatureInvalid
-		}
-		return []byte(tokenSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.keyfunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+	)
 
 	if err != nil {
-		return uuid.Nil, err
+		return nil, err
 	}
 
 	if !token.Valid {
-		return uuid.Nil, jwt.ErrTokenInvalidClaims
+		return nil, jwt.ErrTokenInvalidClaims
 	}
+	if _, err := uuid.Parse(claims.Subject); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// UserID is the user the token was issued to. ParseJWT has already checked
+// that Subject is a UUID.
+func (c *Claims) UserID() uuid.UUID {
+	return uuid.MustParse(c.Subject)
+}
 
-	userID, err := uuid.Parse(claims.Subject)
+func ValidateJWT(tokenString string, keys *KeySet) (uuid.UUID, error) {
+	claims, err := ParseJWT(tokenString, keys)
 	if err != nil {
 		return uuid.Nil, err
 	}
-
-	return userID, nil
+	return claims.UserID(), nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -89,27 +107,24 @@ func GetBearerToken(headers http.Header) (string, error) {
 	return stringAnsw, nil
 }
 func MakeRefreshToken() (string, error) {
+	return MakeToken()
+}
+
+// MakeToken returns a random 256-bit token, hex encoded, for links and
+// sessions that are looked up by value.
+func MakeToken() (string, error) {
 	b := make([]byte, 32)
 	_, err := rand.Read(b)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 	hexStr := hex.EncodeToString([]byte(b))
-	return hexStr, err
+	return hexStr, nil
 }
-func GetAPIKey(headers http.Header) (string, error) {
-	authHeader := headers.Get("Authorization")
-	if authHeader == "" {
-		return "", errors.New("authorization header missing")
-	}
-	const prefix = "ApiKey "
-	if !strings.HasPrefix(authHeader, prefix) {
-		return "", errors.New("authorization header must start with ApiKey")
-	}
-	stringAnsw := strings.TrimPrefix(authHeader, prefix)
-	stringAnsw = strings.TrimSpace(stringAnsw)
-	if stringAnsw == "" {
-		return "", errors.New("authorization header contains empty apykey")
-	}
-	return stringAnsw, nil
+
+// HashToken returns the digest stored in place of a token from MakeToken, so
+// reading the database does not hand out usable sessions or links.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
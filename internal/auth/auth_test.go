@@ -1,10 +1,12 @@
 package auth
 
 import (
+	"errors"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -22,9 +24,9 @@ func TestHashAndCheckPassword(t *testing.T) {
 		t.Errorf("Hash está vacío")
 	}
 
-	// 2) Verificar que el hash tiene prefijo de bcrypt
-	if !strings.HasPrefix(hash, "$2") {
-		t.Errorf("Hash no tiene prefijo bcrypt esperado: %s", hash)
+	// 2) Verificar que el hash tiene prefijo de argon2id
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		t.Errorf("Hash no tiene prefijo argon2id esperado: %s", hash)
 	}
 
 	// 3) Verificar que la contraseña coincide
@@ -38,26 +40,26 @@ func TestHashAndCheckPassword(t *testing.T) {
 		t.Errorf("CheckPasswordHash() debería fallar con contraseña incorrecta")
 	}
 
-	// 5) Verificar que generar otro hash con la misma password da un hash distinto (bcrypt usa salt)
+	// 5) Verificar que generar otro hash con la misma password da un hash distinto (argon2id usa salt)
 	hash2, err := HashPassword(password)
 	if err != nil {
 		t.Fatalf("HashPassword() error al generar segundo hash: %v", err)
 	}
 	if hash == hash2 {
-		t.Errorf("Hash repetido: bcrypt debería generar un hash distinto aunque la contraseña sea igual")
+		t.Errorf("Hash repetido: argon2id debería generar un hash distinto aunque la contraseña sea igual")
 	}
 }
 func TestMakeAndValidateJWT(t *testing.T) {
-	secret := "mySuperSecretKey"
+	keys := newTestKeySet(t)
 	userID := uuid.New()
 	expiration := time.Minute * 5
 
-	token, err := MakeJWT(userID, secret, expiration)
+	token, err := MakeJWT(userID, RoleUser, keys, expiration)
 	if err != nil {
 		t.Fatalf("MakeJWT error: %v", err)
 	}
 
-	returnedID, err := ValidateJWT(token, secret)
+	returnedID, err := ValidateJWT(token, keys)
 	if err != nil {
 		t.Fatalf("ValidateJWT error: %v", err)
 	}
@@ -66,3 +68,146 @@ func TestMakeAndValidateJWT(t *testing.T) {
 		t.Fatalf("Expected userID %v but got %v", userID, returnedID)
 	}
 }
+
+func TestMakeJWTHonorsExpiresIn(t *testing.T) {
+	keys := newTestKeySet(t)
+	userID := uuid.New()
+
+	for _, expiresIn := range []time.Duration{time.Minute, time.Hour, 48 * time.Hour} {
+		token, err := MakeJWT(userID, RoleUser, keys, expiresIn)
+		if err != nil {
+			t.Fatalf("MakeJWT error: %v", err)
+		}
+		claims := &jwt.RegisteredClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+			t.Fatalf("ParseUnverified error: %v", err)
+		}
+		got := claims.ExpiresAt.Sub(claims.IssuedAt.Time)
+		if got != expiresIn {
+			t.Errorf("token lifetime = %v, want %v", got, expiresIn)
+		}
+	}
+}
+
+func TestValidateJWTRejectsExpired(t *testing.T) {
+	keys := newTestKeySet(t)
+	userID := uuid.New()
+
+	token, err := MakeJWT(userID, RoleUser, keys, -time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+	_, err = ValidateJWT(token, keys)
+	if !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Fatalf("ValidateJWT error = %v, want %v", err, jwt.ErrTokenExpired)
+	}
+}
+
+func TestValidateJWTExpiresAfterLifetime(t *testing.T) {
+	keys := newTestKeySet(t)
+	userID := uuid.New()
+
+	token, err := MakeJWT(userID, RoleUser, keys, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+	if _, err := ValidateJWT(token, keys); err != nil {
+		t.Fatalf("ValidateJWT error before expiry: %v", err)
+	}
+
+	// Check it with the clock moved past the lifetime instead of waiting.
+	later := func() time.Time { return time.Now().Add(2 * time.Minute) }
+	_, err = jwt.ParseWithClaims(token, &Claims{}, keys.keyfunc, jwt.WithTimeFunc(later))
+	if !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Fatalf("ParseWithClaims error after expiry = %v, want %v", err, jwt.ErrTokenExpired)
+	}
+}
+
+func TestValidateJWTWrongKeySet(t *testing.T) {
+	token, err := MakeJWT(uuid.New(), RoleUser, newTestKeySet(t), time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+	if _, err := ValidateJWT(token, newTestKeySet(t)); err == nil {
+		t.Fatalf("ValidateJWT debería fallar con otras claves")
+	}
+}
+
+func TestValidateJWTRejectsHS256(t *testing.T) {
+	claims := jwt.RegisteredClaims{
+		Subject:   uuid.New().String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = "whatever"
+	s, err := token.SignedString([]byte("mySuperSecretKey"))
+	if err != nil {
+		t.Fatalf("SignedString error: %v", err)
+	}
+	if _, err := ValidateJWT(s, newTestKeySet(t)); err == nil {
+		t.Fatalf("ValidateJWT debería rechazar tokens HS256")
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("MakeRefreshToken error: %v", err)
+	}
+	hash := HashToken(token)
+	if hash == token {
+		t.Fatalf("HashToken devolvió el token sin hashear")
+	}
+	if hash != HashToken(token) {
+		t.Fatalf("HashToken debería ser determinista")
+	}
+	if len(hash) != 64 {
+		t.Fatalf("len(hash) = %d, want 64", len(hash))
+	}
+}
+
+func newTestKeySet(t *testing.T) *KeySet {
+	t.Helper()
+	keys, err := NewKeySet()
+	if err != nil {
+		t.Fatalf("NewKeySet error: %v", err)
+	}
+	return keys
+}
+
+func TestParseJWTCarriesRole(t *testing.T) {
+	keys := newTestKeySet(t)
+	userID := uuid.New()
+	token, err := MakeJWT(userID, RoleAdmin, keys, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+	claims, err := ParseJWT(token, keys)
+	if err != nil {
+		t.Fatalf("ParseJWT error: %v", err)
+	}
+	if claims.UserID() != userID || claims.Role != RoleAdmin {
+		t.Fatalf("claims = (%s, %q), want (%s, %q)", claims.UserID(), claims.Role, userID, RoleAdmin)
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	cases := []struct {
+		role, want string
+		ok         bool
+	}{
+		{RoleAdmin, RoleAdmin, true},
+		{RoleAdmin, RoleModerator, true},
+		{RoleModerator, RoleAdmin, false},
+		{RoleUser, RoleModerator, false},
+		{"", RoleUser, true},
+		{"", RoleAdmin, false},
+		{"root", RoleUser, false},
+		{RoleAdmin, "root", false},
+	}
+	for _, c := range cases {
+		if got := HasRole(c.role, c.want); got != c.ok {
+			t.Errorf("HasRole(%q, %q) = %v, want %v", c.role, c.want, got, c.ok)
+		}
+	}
+}
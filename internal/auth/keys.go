@@ -0,0 +1,376 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeySet holds the Ed25519 keys used to sign and verify access tokens. Only
+// the newest published key signs; older ones keep verifying until every
+// token they signed has expired, so rotating keys never logs anyone out.
+type KeySet struct {
+	mu   sync.RWMutex
+	dir  string
+	keys map[string]*keyPair
+	// publishDelay is how long a new key is only published in the JWKS
+	// before it starts signing, see SetPublishDelay.
+	publishDelay time.Duration
+	// lastReload limits how often lookups of an unknown kid or the JWKS go
+	// back to the directory.
+	lastReload time.Time
+}
+
+type keyPair struct {
+	private   ed25519.PrivateKey
+	public    ed25519.PublicKey
+	createdAt time.Time
+}
+
+// SetPublishDelay makes new keys wait d after Rotate before they sign, so
+// verifiers that cache the JWKS for up to d already know them. A new key
+// set signs with its first key straight away.
+func (ks *KeySet) SetPublishDelay(d time.Duration) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.publishDelay = d
+}
+
+// reloadInterval is the least time between reloads triggered by lookups.
+const reloadInterval = 5 * time.Second
+
+// JWK is the public half of a signing key in RFC 8037 form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewKeySet returns a key set that only lives in memory, with one fresh
+// signing key.
+func NewKeySet() (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*keyPair)}
+	if _, err := ks.Rotate(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// LoadKeySet reads every <kid>.pem private key in dir. The newest key signs
+// and the rest are kept for verification. A key is generated when the
+// directory is empty, and keys created by Rotate are written back to it.
+//
+// Several instances may share the directory as long as they call Reload
+// regularly: each picks up the keys the others rotate in, and lookups of a
+// kid it doesn't know, or of the JWKS, reload it too. Prune only drops keys
+// retired longer ago than the retention, by which time every instance has
+// moved on to a newer one.
+func LoadKeySet(dir string) (*KeySet, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	ks := &KeySet{dir: dir, keys: make(map[string]*keyPair)}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	if len(ks.keys) == 0 {
+		if _, err := ks.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// Reload re-reads the key directory, so keys rotated in or pruned by other
+// instances sharing it are picked up. It does nothing for a key set that
+// only lives in memory, and keeps the current keys if the directory is
+// empty.
+func (ks *KeySet) Reload() error {
+	if ks.dir == "" {
+		return nil
+	}
+	keys, err := readKeyDir(ks.dir)
+	if err != nil {
+		return err
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.lastReload = time.Now()
+	if len(keys) > 0 {
+		ks.keys = keys
+	}
+	return nil
+}
+
+// reloadIfDue reloads unless that was done in the last reloadInterval.
+// Errors leave the current keys in place.
+func (ks *KeySet) reloadIfDue() {
+	if ks.dir == "" {
+		return
+	}
+	ks.mu.Lock()
+	due := time.Since(ks.lastReload) >= reloadInterval
+	if due {
+		ks.lastReload = time.Now()
+	}
+	ks.mu.Unlock()
+	if due {
+		ks.Reload()
+	}
+}
+
+// readKeyDir loads the keys in dir. A key's file is written when it is
+// created, so its modification time is the key's creation time. Files that
+// can't be read as keys are skipped rather than failing the whole set.
+func readKeyDir(dir string) (map[string]*keyPair, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*keyPair)
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		info, err := os.Stat(path)
+		if err != nil {
+			// Pruned by another instance since the Glob.
+			continue
+		}
+		private, err := readPrivateKey(path)
+		if err != nil {
+			continue
+		}
+		keys[kid] = &keyPair{
+			private:   private,
+			public:    private.Public().(ed25519.PublicKey),
+			createdAt: info.ModTime(),
+		}
+	}
+	return keys, nil
+}
+
+// Rotate generates a new key. It is published in the JWKS at once and
+// takes over signing once the publish delay has passed; the key it replaces
+// stays valid for verification until Prune removes it.
+func (ks *KeySet) Rotate() (string, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	kid, err := newKid()
+	if err != nil {
+		return "", err
+	}
+	if ks.dir != "" {
+		if err := writePrivateKey(filepath.Join(ks.dir, kid+".pem"), private); err != nil {
+			return "", err
+		}
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[kid] = &keyPair{private: private, public: public, createdAt: time.Now()}
+	return kid, nil
+}
+
+// NewestKeyAge is how long ago the newest key was created, whether or not
+// it signs yet.
+func (ks *KeySet) NewestKeyAge() time.Duration {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	kids := ks.sortedKids()
+	if len(kids) == 0 {
+		return 0
+	}
+	return time.Since(ks.keys[kids[len(kids)-1]].createdAt)
+}
+
+// Prune drops keys that were replaced as the signing key more than
+// retention ago. retention should be at least the access token lifetime, so
+// no live token loses its key. It returns the kids that were removed.
+func (ks *KeySet) Prune(retention time.Duration) []string {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	now := time.Now()
+	signing := ks.signingKid(now)
+	kids := ks.sortedKids()
+	var removed []string
+	for i, kid := range kids {
+		if kid >= signing {
+			break
+		}
+		// A key is retired when the key after it starts signing.
+		retiredAt := ks.keys[kids[i+1]].createdAt.Add(ks.publishDelay)
+		if now.Sub(retiredAt) < retention {
+			continue
+		}
+		delete(ks.keys, kid)
+		if ks.dir != "" {
+			os.Remove(filepath.Join(ks.dir, kid+".pem"))
+		}
+		removed = append(removed, kid)
+	}
+	return removed
+}
+
+// signingKid is the newest key that has been published for the publish
+// delay, or the oldest key if none has, as when the set was just created.
+// The caller holds ks.mu.
+func (ks *KeySet) signingKid(now time.Time) string {
+	kids := ks.sortedKids()
+	for i := len(kids) - 1; i >= 0; i-- {
+		if !ks.keys[kids[i]].createdAt.Add(ks.publishDelay).After(now) {
+			return kids[i]
+		}
+	}
+	if len(kids) == 0 {
+		return ""
+	}
+	return kids[0]
+}
+
+// sortedKids lists the kids oldest first, see newKid. The caller holds
+// ks.mu.
+func (ks *KeySet) sortedKids() []string {
+	kids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	return kids
+}
+
+// JWKS returns the public keys that currently verify tokens.
+func (ks *KeySet) JWKS() JWKS {
+	ks.reloadIfDue()
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for kid, key := range ks.keys {
+		set.Keys = append(set.Keys, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key.public),
+			Kid: kid,
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+			Use: "sig",
+		})
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	ks.mu.RLock()
+	kid := ks.signingKid(time.Now())
+	key, ok := ks.keys[kid]
+	ks.mu.RUnlock()
+	if !ok {
+		return "", errors.New("no signing key")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key.private)
+}
+
+func (ks *KeySet) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid")
+	}
+	key, ok := ks.publicKey(kid)
+	if !ok {
+		// The key may have been rotated in by another instance.
+		ks.reloadIfDue()
+		key, ok = ks.publicKey(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	return key, nil
+}
+
+func (ks *KeySet) publicKey(kid string) (ed25519.PublicKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, false
+	}
+	return key.public, true
+}
+
+// newKid returns a key ID that sorts by creation time.
+func newKid() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return time.Now().UTC().Format("20060102T150405.000000000Z") + "-" + hex.EncodeToString(b), nil
+}
+
+func readPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an Ed25519 key")
+	}
+	return private, nil
+}
+
+// writePrivateKey writes to a temporary file first and renames it into
+// place, so instances reading the directory never see half a key.
+func writePrivateKey(path string, private ed25519.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".key-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
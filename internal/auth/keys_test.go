@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRotateKeepsOldTokensValid(t *testing.T) {
+	keys := newTestKeySet(t)
+	userID := uuid.New()
+
+	oldToken, err := MakeJWT(userID, RoleUser, keys, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+	if _, err := keys.Rotate(); err != nil {
+		t.Fatalf("Rotate error: %v", err)
+	}
+	newToken, err := MakeJWT(userID, RoleUser, keys, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+
+	for _, token := range []string{oldToken, newToken} {
+		if _, err := ValidateJWT(token, keys); err != nil {
+			t.Errorf("ValidateJWT error after rotation: %v", err)
+		}
+	}
+	if n := len(keys.JWKS().Keys); n != 2 {
+		t.Errorf("JWKS has %d keys, want 2", n)
+	}
+
+	// Once the retention has passed, the retired key is dropped and its
+	// tokens stop verifying.
+	if removed := keys.Prune(0); len(removed) != 1 {
+		t.Fatalf("Prune removed %v, want one key", removed)
+	}
+	if _, err := ValidateJWT(oldToken, keys); err == nil {
+		t.Errorf("ValidateJWT debería fallar con una clave eliminada")
+	}
+	if _, err := ValidateJWT(newToken, keys); err != nil {
+		t.Errorf("ValidateJWT error with signing key: %v", err)
+	}
+}
+
+func TestPruneKeepsRecentlyRetiredKeys(t *testing.T) {
+	keys := newTestKeySet(t)
+	if _, err := keys.Rotate(); err != nil {
+		t.Fatalf("Rotate error: %v", err)
+	}
+	if removed := keys.Prune(time.Hour); len(removed) != 0 {
+		t.Errorf("Prune removed %v, want none", removed)
+	}
+}
+
+func TestLoadKeySetPersistsKeys(t *testing.T) {
+	dir := t.TempDir()
+	keys, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatalf("LoadKeySet error: %v", err)
+	}
+	token, err := MakeJWT(uuid.New(), RoleUser, keys, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+	kid, err := keys.Rotate()
+	if err != nil {
+		t.Fatalf("Rotate error: %v", err)
+	}
+
+	reloaded, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatalf("LoadKeySet error: %v", err)
+	}
+	if _, err := ValidateJWT(token, reloaded); err != nil {
+		t.Errorf("ValidateJWT error after reload: %v", err)
+	}
+	if signing := reloaded.signingKid(time.Now()); signing != kid {
+		t.Errorf("signing kid = %q, want %q", signing, kid)
+	}
+}
+
+func TestReloadSharesKeysBetweenInstances(t *testing.T) {
+	dir := t.TempDir()
+	a, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatalf("LoadKeySet error: %v", err)
+	}
+	b, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatalf("LoadKeySet error: %v", err)
+	}
+
+	kid, err := a.Rotate()
+	if err != nil {
+		t.Fatalf("Rotate error: %v", err)
+	}
+	token, err := MakeJWT(uuid.New(), RoleUser, a, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+	// b has never seen the new key; the unknown kid makes it look again,
+	// once the last reload is far enough back.
+	b.lastReload = time.Time{}
+	if _, err := ValidateJWT(token, b); err != nil {
+		t.Errorf("ValidateJWT error on the other instance: %v", err)
+	}
+	if err := b.Reload(); err != nil {
+		t.Fatalf("Reload error: %v", err)
+	}
+	if signing := b.signingKid(time.Now()); signing != kid {
+		t.Errorf("signing kid = %q, want %q", signing, kid)
+	}
+
+	// Once a prunes the retired key, b drops it on its next reload.
+	if removed := a.Prune(0); len(removed) != 1 {
+		t.Fatalf("Prune removed %v, want one key", removed)
+	}
+	if err := b.Reload(); err != nil {
+		t.Fatalf("Reload error: %v", err)
+	}
+	if n := len(b.JWKS().Keys); n != 1 {
+		t.Errorf("JWKS has %d keys, want 1", n)
+	}
+}
+
+func TestRotatedKeyIsPublishedBeforeItSigns(t *testing.T) {
+	keys := newTestKeySet(t)
+	keys.SetPublishDelay(time.Minute)
+	first := keys.signingKid(time.Now())
+
+	kid, err := keys.Rotate()
+	if err != nil {
+		t.Fatalf("Rotate error: %v", err)
+	}
+	if n := len(keys.JWKS().Keys); n != 2 {
+		t.Errorf("JWKS has %d keys, want 2", n)
+	}
+	if signing := keys.signingKid(time.Now()); signing != first {
+		t.Errorf("signing kid right after Rotate = %q, want the old %q", signing, first)
+	}
+	if signing := keys.signingKid(time.Now().Add(time.Minute)); signing != kid {
+		t.Errorf("signing kid after the delay = %q, want %q", signing, kid)
+	}
+	// The old key still signs, so it can't be pruned yet.
+	if removed := keys.Prune(0); len(removed) != 0 {
+		t.Errorf("Prune removed %v, want none", removed)
+	}
+}
+
+func TestReloadSkipsUnreadableFiles(t *testing.T) {
+	dir := t.TempDir()
+	keys, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatalf("LoadKeySet error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "zzz.pem"), []byte("-----BEGIN"), 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	if err := keys.Reload(); err != nil {
+		t.Fatalf("Reload error: %v", err)
+	}
+	if _, err := LoadKeySet(dir); err != nil {
+		t.Fatalf("LoadKeySet error with a broken file: %v", err)
+	}
+	if n := len(keys.JWKS().Keys); n != 1 {
+		t.Errorf("JWKS has %d keys, want 1", n)
+	}
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// Verifier turns a bearer token into the claims it stands for.
+type Verifier interface {
+	Verify(ctx context.Context, token string) (*Claims, error)
+}
+
+// Verify checks an access token signed by the key set.
+func (ks *KeySet) Verify(ctx context.Context, token string) (*Claims, error) {
+	return ParseJWT(token, ks)
+}
+
+type contextKey int
+
+const claimsKey contextKey = iota
+
+func ContextWithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// ClaimsFromContext returns the claims RequireAuth or OptionalAuth stored
+// for the request, if the caller is authenticated.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*Claims)
+	return claims, ok
+}
+
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return uuid.Nil, false
+	}
+	return claims.UserID(), true
+}
+
+// RoleFromContext returns the caller's role; anonymous callers and tokens
+// without a role get RoleUser.
+func RoleFromContext(ctx context.Context) string {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok || claims.Role == "" {
+		return RoleUser
+	}
+	return claims.Role
+}
+
+// MustUserID is UserIDFromContext for handlers behind RequireAuth. It
+// panics when there is no user, which means the route was registered
+// without the middleware.
+func MustUserID(ctx context.Context) uuid.UUID {
+	userID, ok := UserIDFromContext(ctx)
+	if !ok {
+		panic("auth: no user in context; is the route wrapped in RequireAuth?")
+	}
+	return userID
+}
+
+// RequireAuth rejects requests without a valid bearer token and puts the
+// token's claims in the request context.
+func RequireAuth(v Verifier, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := GetBearerToken(r.Header)
+		if err != nil {
+			Unauthorized(w, "")
+			return
+		}
+		claims, err := v.Verify(r.Context(), token)
+		if err != nil {
+			Unauthorized(w, "invalid_token")
+			return
+		}
+		next(w, r.WithContext(ContextWithClaims(r.Context(), claims)))
+	}
+}
+
+// OptionalAuth lets anonymous requests through, but a token that is present
+// must be valid, so clients learn when theirs has expired.
+func OptionalAuth(v Verifier, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next(w, r)
+			return
+		}
+		RequireAuth(v, next)(w, r)
+	}
+}
+
+// RequireRole only lets through callers with role or a higher one. It reads
+// the role RequireAuth stored, so it has to be wrapped by it.
+func RequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := ClaimsFromContext(r.Context()); !ok {
+			Unauthorized(w, "")
+			return
+		}
+		if !HasRole(RoleFromContext(r.Context()), role) {
+			writeError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Unauthorized writes the 401 every authenticated route answers with. code
+// is the RFC 6750 error code, such as "invalid_token", or empty when no
+// credentials were sent.
+func Unauthorized(w http.ResponseWriter, code string) {
+	challenge := `Bearer realm="chirpy"`
+	if code != "" {
+		challenge += `, error="` + code + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	writeError(w, http.StatusUnauthorized, "Unauthorized")
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
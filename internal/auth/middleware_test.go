@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func serve(h http.HandlerFunc, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec
+}
+
+func TestRequireAuth(t *testing.T) {
+	keys := newTestKeySet(t)
+	userID := uuid.New()
+	token, err := MakeJWT(userID, RoleModerator, keys, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+	var got uuid.UUID
+	var role string
+	h := RequireAuth(keys, func(w http.ResponseWriter, r *http.Request) {
+		got = MustUserID(r.Context())
+		role = RoleFromContext(r.Context())
+	})
+
+	if rec := serve(h, token); rec.Code != 200 || got != userID || role != RoleModerator {
+		t.Fatalf("con token válido: code=%d user=%s role=%q", rec.Code, got, role)
+	}
+
+	rec := serve(h, "")
+	if rec.Code != 401 || rec.Header().Get("WWW-Authenticate") != `Bearer realm="chirpy"` {
+		t.Fatalf("sin token: code=%d WWW-Authenticate=%q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+	rec = serve(h, "basura")
+	if rec.Code != 401 || rec.Header().Get("WWW-Authenticate") != `Bearer realm="chirpy", error="invalid_token"` {
+		t.Fatalf("token inválido: code=%d WWW-Authenticate=%q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	keys := newTestKeySet(t)
+	var authenticated bool
+	h := OptionalAuth(keys, func(w http.ResponseWriter, r *http.Request) {
+		_, authenticated = UserIDFromContext(r.Context())
+	})
+	if rec := serve(h, ""); rec.Code != 200 || authenticated {
+		t.Fatalf("anónimo: code=%d authenticated=%v", rec.Code, authenticated)
+	}
+	if rec := serve(h, "basura"); rec.Code != 401 {
+		t.Fatalf("token inválido: code=%d, want 401", rec.Code)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	keys := newTestKeySet(t)
+	h := RequireAuth(keys, RequireRole(RoleAdmin, func(w http.ResponseWriter, r *http.Request) {}))
+	for role, want := range map[string]int{RoleUser: 403, RoleModerator: 403, RoleAdmin: 200} {
+		token, err := MakeJWT(uuid.New(), role, keys, time.Minute)
+		if err != nil {
+			t.Fatalf("MakeJWT error: %v", err)
+		}
+		if rec := serve(h, token); rec.Code != want {
+			t.Errorf("role %q: code=%d, want %d", role, rec.Code, want)
+		}
+	}
+}
+
+func TestRequireScopeAndSession(t *testing.T) {
+	pat := &Claims{Scoped: true, Scopes: []string{ScopeChirpsRead}}
+	pat.Subject = uuid.NewString()
+	session := &Claims{}
+	session.Subject = uuid.NewString()
+
+	call := func(h http.HandlerFunc, claims *Claims) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req = req.WithContext(ContextWithClaims(req.Context(), claims))
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec.Code
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	if code := call(RequireScope(ScopeChirpsRead, ok), pat); code != 200 {
+		t.Errorf("scope concedido: code=%d, want 200", code)
+	}
+	if code := call(RequireScope(ScopeChirpsWrite, ok), pat); code != 403 {
+		t.Errorf("scope no concedido: code=%d, want 403", code)
+	}
+	if code := call(RequireScope(ScopeChirpsWrite, ok), session); code != 200 {
+		t.Errorf("sesión sin scopes: code=%d, want 200", code)
+	}
+	if code := call(RequireSession(ok), pat); code != 403 {
+		t.Errorf("RequireSession con token personal: code=%d, want 403", code)
+	}
+	if code := call(RequireSession(ok), session); code != 200 {
+		t.Errorf("RequireSession con sesión: code=%d, want 200", code)
+	}
+}
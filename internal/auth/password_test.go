@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPasswordHashLegacyBcrypt(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("vieja_clave"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt error: %v", err)
+	}
+	if err := CheckPasswordHash("vieja_clave", string(legacy)); err != nil {
+		t.Fatalf("CheckPasswordHash con hash bcrypt falló: %v", err)
+	}
+	if err := CheckPasswordHash("otra", string(legacy)); err == nil {
+		t.Fatalf("CheckPasswordHash debería fallar con contraseña incorrecta")
+	}
+	if !NeedsRehash(string(legacy)) {
+		t.Fatalf("un hash bcrypt debería necesitar rehash")
+	}
+}
+
+func TestHashPasswordUsesWholePassword(t *testing.T) {
+	long := strings.Repeat("a", 80)
+	hash, err := HashPassword(long + "1")
+	if err != nil {
+		t.Fatalf("HashPassword error: %v", err)
+	}
+	if err := CheckPasswordHash(long+"2", hash); err == nil {
+		t.Fatalf("contraseñas que solo difieren después de 72 bytes no deberían coincidir")
+	}
+}
+
+func TestNeedsRehashOnParamChange(t *testing.T) {
+	hash, err := HashPassword("clave")
+	if err != nil {
+		t.Fatalf("HashPassword error: %v", err)
+	}
+	if NeedsRehash(hash) {
+		t.Fatalf("un hash con los parámetros actuales no necesita rehash")
+	}
+
+	old := passwordParams
+	defer func() { passwordParams = old }()
+	stronger := old
+	stronger.Iterations++
+	if err := SetArgon2Params(stronger); err != nil {
+		t.Fatalf("SetArgon2Params error: %v", err)
+	}
+	if !NeedsRehash(hash) {
+		t.Fatalf("un hash con parámetros viejos debería necesitar rehash")
+	}
+	if err := CheckPasswordHash("clave", hash); err != nil {
+		t.Fatalf("el hash viejo debería seguir verificando: %v", err)
+	}
+}
+
+func TestCheckPasswordHashRejectsMalformed(t *testing.T) {
+	for _, hash := range []string{"", "plain", "$argon2id$v=19$m=1$x$y", "$argon2i$v=19$m=65536,t=3,p=2$c2FsdA$a2V5"} {
+		if err := CheckPasswordHash("clave", hash); err == nil {
+			t.Errorf("CheckPasswordHash(%q) debería fallar", hash)
+		}
+	}
+}
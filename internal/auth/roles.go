@@ -0,0 +1,29 @@
+package auth
+
+// Roles, from least to most privileged. Each role can do everything the
+// ones before it can.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRank = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// HasRole reports whether role grants at least want. Tokens issued before
+// roles existed carry none and count as RoleUser.
+func HasRole(role, want string) bool {
+	if role == "" {
+		role = RoleUser
+	}
+	return roleRank[role] >= roleRank[want] && ValidRole(want)
+}
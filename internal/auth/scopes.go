@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Scopes a personal access token can be granted. Login sessions are not
+// scoped and can do everything their role allows.
+const (
+	ScopeChirpsRead  = "chirps:read"
+	ScopeChirpsWrite = "chirps:write"
+	ScopeUsersWrite  = "users:write"
+)
+
+var allScopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeUsersWrite}
+
+// PersonalTokenPrefix marks personal access tokens, so they can be told
+// apart from JWTs and spotted by secret scanners.
+const PersonalTokenPrefix = "chirpy_pat_"
+
+func ValidScope(scope string) bool {
+	return slices.Contains(allScopes, scope)
+}
+
+// MakePersonalToken returns a new personal access token. Like other tokens
+// from MakeToken it is stored with HashToken.
+func MakePersonalToken() (string, error) {
+	token, err := MakeToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalTokenPrefix + token, nil
+}
+
+func IsPersonalToken(token string) bool {
+	return strings.HasPrefix(token, PersonalTokenPrefix)
+}
+
+// HasScope reports whether the credentials allow scope. Only personal access
+// tokens are limited.
+func (c *Claims) HasScope(scope string) bool {
+	return !c.Scoped || slices.Contains(c.Scopes, scope)
+}
+
+// RequireScope rejects personal access tokens that weren't granted scope.
+// It has to be wrapped by RequireAuth.
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext(r.Context())
+		if !ok {
+			Unauthorized(w, "")
+			return
+		}
+		if !claims.HasScope(scope) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="chirpy", error="insufficient_scope", scope="`+scope+`"`)
+			writeError(w, http.StatusForbidden, "Token lacks scope "+scope)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RequireSession keeps personal access tokens away from account management,
+// such as changing the password or minting more tokens. It has to be
+// wrapped by RequireAuth.
+func RequireSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext(r.Context())
+		if !ok {
+			Unauthorized(w, "")
+			return
+		}
+		if claims.Scoped {
+			writeError(w, http.StatusForbidden, "Personal access tokens can't be used here")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Vectors from RFC 6238 appendix B, truncated to six digits.
+func TestValidateTOTPRFCVectors(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, c := range cases {
+		if _, ok := ValidateTOTP(secret, c.code, time.Unix(c.unix, 0)); !ok {
+			t.Errorf("ValidateTOTP(%d, %s) = false, want true", c.unix, c.code)
+		}
+	}
+}
+
+func TestValidateTOTPWindow(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret error: %v", err)
+	}
+	key, _ := totpEncoding.DecodeString(secret)
+	now := time.Unix(1_700_000_000, 0)
+	step := now.Unix() / totpPeriod
+
+	got, ok := ValidateTOTP(secret, totpCode(key, step-1), now)
+	if !ok || got != step-1 {
+		t.Fatalf("código del paso anterior debería aceptarse, got (%d, %v)", got, ok)
+	}
+	if _, ok := ValidateTOTP(secret, totpCode(key, step+3), now); ok {
+		t.Fatalf("código fuera de la ventana debería rechazarse")
+	}
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Fatalf("código de largo incorrecto debería rechazarse")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("Chirpy", "user@example.com", "ABC")
+	if !strings.HasPrefix(uri, "otpauth://totp/Chirpy:user@example.com?") {
+		t.Fatalf("uri = %q", uri)
+	}
+	if !strings.Contains(uri, "secret=ABC") || !strings.Contains(uri, "issuer=Chirpy") {
+		t.Fatalf("uri = %q, falta secret o issuer", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes error: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if len(c) != 11 || c[5] != '-' {
+			t.Fatalf("código con formato inesperado: %q", c)
+		}
+		if seen[c] {
+			t.Fatalf("código repetido: %q", c)
+		}
+		seen[c] = true
+		typed := strings.ToUpper(strings.ReplaceAll(c, "-", " "))
+		if NormalizeRecoveryCode(typed) != c {
+			t.Fatalf("NormalizeRecoveryCode(%q) = %q, want %q", typed, NormalizeRecoveryCode(typed), c)
+		}
+	}
+}
+
+func TestSecretBoxRoundTrip(t *testing.T) {
+	box, err := NewSecretBox(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("NewSecretBox error: %v", err)
+	}
+	sealed, err := box.Seal([]byte("JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+	plain, err := box.Open(sealed)
+	if err != nil || string(plain) != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("Open = %q, %v", plain, err)
+	}
+	other, _ := NewSecretBox(bytes.Repeat([]byte{8}, 32))
+	if _, err := other.Open(sealed); err == nil {
+		t.Fatalf("Open con otra clave debería fallar")
+	}
+}
@@ -7,18 +7,37 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
-const deleteChirp = `-- name: DeleteChirp :one
-DELETE FROM chirps
-WHERE id=$1
-RETURNING id, created_at, updated_at, body, user_id
+const editChirp = `-- name: EditChirp :one
+WITH revision AS (
+    INSERT INTO chirp_revisions (id, chirp_id, body, created_at)
+    SELECT gen_random_uuid(), chirps.id, chirps.body, NOW()
+    FROM chirps
+    WHERE chirps.id = $1
+    AND chirps.deleted_at IS NULL
+)
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    edited_at = NOW(),
+    body = $2
+WHERE chirps.id = $1
+AND chirps.deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, edited_at, deleted_at
 `
 
-func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, deleteChirp, id)
+type EditChirpParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) EditChirp(ctx context.Context, arg EditChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, editChirp, arg.ID, arg.Body)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -26,17 +45,79 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.EditedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
-const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+const getChirpRevisions = `-- name: GetChirpRevisions :many
+SELECT id, chirp_id, body, created_at FROM chirp_revisions
+WHERE chirp_id=$1
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetChirpRevisions(ctx context.Context, chirpID uuid.UUID) ([]ChirpRevision, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpRevisions, chirpID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpRevision
+	for rows.Next() {
+		var i ChirpRevision
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChirpID,
+			&i.Body,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsPageAsc = `-- name: GetChirpsPageAsc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, edited_at, deleted_at FROM chirps
+WHERE deleted_at IS NULL
+AND ($1::uuid IS NULL OR user_id = $1::uuid)
+AND ($2::timestamp IS NULL OR created_at >= $2::timestamp)
+AND ($3::timestamp IS NULL OR created_at < $3::timestamp)
+AND (
+    $4::timestamp IS NULL
+    OR (created_at, id) > ($4::timestamp, $5::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT $6
+`
+
+type GetChirpsPageAscParams struct {
+	AuthorID        uuid.NullUUID
+	Since           sql.NullTime
+	Until           sql.NullTime
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) GetChirpsPageAsc(ctx context.Context, arg GetChirpsPageAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPageAsc,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -50,6 +131,9 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.EditedAt,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -64,14 +148,38 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirpsByAuthor = `-- name: GetChirpsByAuthor :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id=$1
-ORDER BY created_at ASC
+const getChirpsPageDesc = `-- name: GetChirpsPageDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, edited_at, deleted_at FROM chirps
+WHERE deleted_at IS NULL
+AND ($1::uuid IS NULL OR user_id = $1::uuid)
+AND ($2::timestamp IS NULL OR created_at >= $2::timestamp)
+AND ($3::timestamp IS NULL OR created_at < $3::timestamp)
+AND (
+    $4::timestamp IS NULL
+    OR (created_at, id) < ($4::timestamp, $5::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT $6
 `
 
-func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByAuthor, userID)
+type GetChirpsPageDescParams struct {
+	AuthorID        uuid.NullUUID
+	Since           sql.NullTime
+	Until           sql.NullTime
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) GetChirpsPageDesc(ctx context.Context, arg GetChirpsPageDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPageDesc,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +193,9 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.EditedAt,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -99,25 +210,170 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 	return items, nil
 }
 
+const getDeletedChirp = `-- name: GetDeletedChirp :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, edited_at, deleted_at FROM chirps
+WHERE id=$1
+AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) GetDeletedChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.EditedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getThread = `-- name: GetThread :many
+WITH RECURSIVE thread AS (
+    SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.in_reply_to, c.edited_at, c.deleted_at, 0 AS depth
+    FROM chirps c
+    WHERE c.id = $1
+    UNION ALL
+    SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.in_reply_to, c.edited_at, c.deleted_at, t.depth + 1
+    FROM chirps c
+    JOIN thread t ON c.in_reply_to = t.id
+)
+SELECT
+    t.id,
+    t.created_at,
+    t.updated_at,
+    CASE WHEN t.deleted_at IS NULL THEN t.body ELSE '' END::text AS body,
+    t.user_id,
+    t.in_reply_to,
+    t.deleted_at IS NOT NULL AS deleted,
+    t.edited_at,
+    t.depth::int AS depth,
+    (SELECT COUNT(*) FROM chirps r WHERE r.in_reply_to = t.id AND r.deleted_at IS NULL) AS reply_count
+FROM thread t
+ORDER BY t.depth, t.created_at, t.id
+`
+
+type GetThreadRow struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Body       string
+	UserID     uuid.UUID
+	InReplyTo  uuid.NullUUID
+	Deleted    bool
+	EditedAt   sql.NullTime
+	Depth      int32
+	ReplyCount int64
+}
+
+func (q *Queries) GetThread(ctx context.Context, id uuid.UUID) ([]GetThreadRow, error) {
+	rows, err := q.db.QueryContext(ctx, getThread, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetThreadRow
+	for rows.Next() {
+		var i GetThreadRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.Deleted,
+			&i.EditedAt,
+			&i.Depth,
+			&i.ReplyCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getThreadRootID = `-- name: GetThreadRootID :one
+WITH RECURSIVE ancestors AS (
+    SELECT id, in_reply_to FROM chirps WHERE chirps.id = $1
+    UNION ALL
+    SELECT c.id, c.in_reply_to
+    FROM chirps c
+    JOIN ancestors a ON c.id = a.in_reply_to
+)
+SELECT ancestors.id FROM ancestors
+WHERE ancestors.in_reply_to IS NULL
+`
+
+func (q *Queries) GetThreadRootID(ctx context.Context, chirpID uuid.UUID) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, getThreadRootID, chirpID)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
+const hasRecentDuplicate = `-- name: HasRecentDuplicate :one
+SELECT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE user_id = $1
+    AND body = $2
+    AND deleted_at IS NULL
+    AND created_at > NOW() - make_interval(secs => $3::float8)
+    AND ($4::uuid IS NULL OR id <> $4::uuid)
+)
+`
+
+type HasRecentDuplicateParams struct {
+	UserID        uuid.UUID
+	Body          string
+	WindowSeconds float64
+	ExcludeID     uuid.NullUUID
+}
+
+func (q *Queries) HasRecentDuplicate(ctx context.Context, arg HasRecentDuplicateParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, hasRecentDuplicate,
+		arg.UserID,
+		arg.Body,
+		arg.WindowSeconds,
+		arg.ExcludeID,
+	)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const insertChirps = `-- name: InsertChirps :one
-INSERT INTO chirps (id,created_at,updated_at,body,user_id) 
+INSERT INTO chirps (id,created_at,updated_at,body,user_id,in_reply_to) 
 VALUES(
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
     )
-    RETURNING id, created_at, updated_at, body, user_id
+    RETURNING id, created_at, updated_at, body, user_id, in_reply_to, edited_at, deleted_at
 `
 
 type InsertChirpsParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	InReplyTo uuid.NullUUID
 }
 
 func (q *Queries) InsertChirps(ctx context.Context, arg InsertChirpsParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, insertChirps, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, insertChirps, arg.Body, arg.UserID, arg.InReplyTo)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -125,13 +381,26 @@ func (q *Queries) InsertChirps(ctx context.Context, arg InsertChirpsParams) (Chi
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.EditedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
+const lockAuthorChirps = `-- name: LockAuthorChirps :exec
+SELECT pg_advisory_xact_lock(hashtext('chirps:' || $1::uuid::text))
+`
+
+func (q *Queries) LockAuthorChirps(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockAuthorChirps, userID)
+	return err
+}
+
 const oneChirps = `-- name: OneChirps :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, edited_at, deleted_at FROM chirps
 WHERE id=$1
+AND deleted_at IS NULL
 `
 
 func (q *Queries) OneChirps(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -143,6 +412,173 @@ func (q *Queries) OneChirps(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.EditedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < NOW() - make_interval(secs => $1::float8)
+AND NOT EXISTS (
+    SELECT 1 FROM chirps r
+    WHERE r.in_reply_to = chirps.id
+)
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, windowSeconds float64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, windowSeconds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    deleted_at = NULL
+WHERE id = $1
+AND deleted_at > NOW() - make_interval(secs => $2::float8)
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, edited_at, deleted_at
+`
+
+type RestoreChirpParams struct {
+	ID            uuid.UUID
+	WindowSeconds float64
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.WindowSeconds)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.EditedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT
+    c.id,
+    c.created_at,
+    c.updated_at,
+    c.body,
+    c.user_id,
+    c.in_reply_to,
+    c.edited_at,
+    ts_rank(to_tsvector('english', c.body), websearch_to_tsquery('english', $1))::float8 AS rank,
+    ts_headline(
+        'english',
+        replace(replace(replace(c.body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+        websearch_to_tsquery('english', $1),
+        'StartSel=<mark>, StopSel=</mark>, MaxFragments=2'
+    )::text AS snippet
+FROM chirps c
+WHERE to_tsvector('english', c.body) @@ websearch_to_tsquery('english', $1)
+AND c.deleted_at IS NULL
+AND ($2::uuid IS NULL OR c.user_id = $2::uuid)
+AND (
+    $3::float8 IS NULL
+    OR (ts_rank(to_tsvector('english', c.body), websearch_to_tsquery('english', $1))::float8, c.created_at, c.id)
+        < ($3::float8, $4::timestamp, $5::uuid)
+)
+ORDER BY rank DESC, c.created_at DESC, c.id DESC
+LIMIT $6
+`
+
+type SearchChirpsParams struct {
+	Query           string
+	AuthorID        uuid.NullUUID
+	CursorRank      sql.NullFloat64
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+type SearchChirpsRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	InReplyTo uuid.NullUUID
+	EditedAt  sql.NullTime
+	Rank      float64
+	Snippet   string
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		arg.AuthorID,
+		arg.CursorRank,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.EditedAt,
+			&i.Rank,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :one
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    deleted_at = NOW()
+WHERE id=$1
+AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, edited_at, deleted_at
+`
+
+func (q *Queries) SoftDeleteChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, softDeleteChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.EditedAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
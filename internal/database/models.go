@@ -11,28 +11,129 @@ import (
 	"github.com/google/uuid"
 )
 
+type BannedWord struct {
+	Term      string
+	CreatedAt time.Time
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	InReplyTo uuid.NullUUID
+	EditedAt  sql.NullTime
+	DeletedAt sql.NullTime
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpRevision struct {
+	ID        uuid.UUID
+	ChirpID   uuid.UUID
+	Body      string
+	CreatedAt time.Time
+}
+
+type EmailVerificationToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type LoginChallenge struct {
+	TokenHash string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Attempts  int32
+	UsedAt    sql.NullTime
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
 }
 
 type RefreshToken struct {
-	Token     string
-	CreatedAt sql.NullTime
-	UpdatedAt sql.NullTime
+	TokenHash  string
+	CreatedAt  sql.NullTime
+	UpdatedAt  sql.NullTime
+	UserID     uuid.UUID
+	ExpiresAt  sql.NullTime
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	ReplacedBy sql.NullString
+	UserAgent  string
+	IpAddress  string
+	LastUsedAt time.Time
+}
+
+type TotpRecoveryCode struct {
 	UserID    uuid.UUID
-	ExpiresAt sql.NullTime
-	RevokedAt sql.NullTime
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    sql.NullBool
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     sql.NullBool
+	EmailVerifiedAt sql.NullTime
+	Role            string
+}
+
+type UserTotp struct {
+	UserID          uuid.UUID
+	SecretEncrypted string
+	CreatedAt       time.Time
+	EnabledAt       sql.NullTime
+	LastStep        int64
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID
+	Provider    string
+	EventID     string
+	EventType   string
+	Payload     string
+	Status      string
+	Error       sql.NullString
+	Attempts    int32
+	ReceivedAt  time.Time
+	ClaimedAt   time.Time
+	ProcessedAt sql.NullTime
 }
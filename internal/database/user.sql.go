@@ -22,7 +22,7 @@ VALUES (
     $1,
     $2  
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role
 `
 
 type CreateUserParams struct {
@@ -40,6 +40,29 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.Role,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role FROM users
+WHERE id=$1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.Role,
 	)
 	return i, err
 }
@@ -58,7 +81,7 @@ func (q *Queries) ReturnHashPassword(ctx context.Context, email string) (string,
 }
 
 const returnUserNotPassword = `-- name: ReturnUserNotPassword :one
-SELECT id,created_at, updated_at, email,is_chirpy_red
+SELECT id,created_at, updated_at, email,is_chirpy_red,role
 FROM users 
 WHERE email=$1
 `
@@ -69,6 +92,7 @@ type ReturnUserNotPasswordRow struct {
 	UpdatedAt   time.Time
 	Email       string
 	IsChirpyRed sql.NullBool
+	Role        string
 }
 
 func (q *Queries) ReturnUserNotPassword(ctx context.Context, email string) (ReturnUserNotPasswordRow, error) {
@@ -80,10 +104,27 @@ func (q *Queries) ReturnUserNotPassword(ctx context.Context, email string) (Retu
 		&i.UpdatedAt,
 		&i.Email,
 		&i.IsChirpyRed,
+		&i.Role,
 	)
 	return i, err
 }
 
+const updatePassword = `-- name: UpdatePassword :exec
+UPDATE users
+SET hashed_password = $2
+WHERE id = $1
+`
+
+type UpdatePasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+}
+
+func (q *Queries) UpdatePassword(ctx context.Context, arg UpdatePasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updatePassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users 
 SET
@@ -91,7 +132,7 @@ SET
     email=$1,
     hashed_password=$2
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role
 `
 
 type UpdateUserParams struct {
@@ -110,6 +151,8 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.Role,
 	)
 	return i, err
 }
@@ -120,7 +163,7 @@ SET
     updated_at = NOW(),
     is_chirpy_red= true
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role
 `
 
 func (q *Queries) UpdateUserIsRed(ctx context.Context, id uuid.UUID) (User, error) {
@@ -133,6 +176,38 @@ func (q *Queries) UpdateUserIsRed(ctx context.Context, id uuid.UUID) (User, erro
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.Role,
+	)
+	return i, err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
+UPDATE users
+SET
+    updated_at = NOW(),
+    role = $2
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, role
+`
+
+type UpdateUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.Role,
 	)
 	return i, err
 }
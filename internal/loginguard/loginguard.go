@@ -0,0 +1,136 @@
+// Package loginguard slows down password guessing. Each key, such as an
+// email or an IP address, gets a few free failures; after that every failure
+// doubles the wait before the next attempt, and enough of them lock the key
+// out for a while.
+package loginguard
+
+import (
+	"context"
+	"time"
+)
+
+// Record is what is remembered about a key between attempts.
+type Record struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store keeps records. The in-memory one serves a single instance; a shared
+// backend, such as Postgres, lets several instances agree on the counts.
+type Store interface {
+	Get(ctx context.Context, key string) (Record, error)
+	// Update applies fn to the current record (zero if there is none) and
+	// stores the result atomically.
+	Update(ctx context.Context, key string, fn func(Record) Record) (Record, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type Policy struct {
+	// FreeAttempts is how many failures are allowed before any delay.
+	FreeAttempts int
+	// BaseDelay is the wait after the first failure past FreeAttempts; it
+	// doubles with each further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutAfter failures lock the key for LockoutFor.
+	LockoutAfter int
+	LockoutFor   time.Duration
+	// ResetAfter is how long a key must go without failures before they
+	// are forgotten.
+	ResetAfter time.Duration
+}
+
+type Guard struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+func New(store Store, policy Policy) *Guard {
+	return &Guard{store: store, policy: policy, now: time.Now}
+}
+
+// Attempt returns how long the caller must wait before key may try again;
+// zero means now. An attempt made now is counted as a failure straight away,
+// so parallel attempts can't all get through before any of them fails. One
+// that turns out fine is taken back with Succeed or Refund. An attempt that
+// has to wait is not counted.
+func (g *Guard) Attempt(ctx context.Context, key string) (time.Duration, error) {
+	now := g.now()
+	var wait time.Duration
+	_, err := g.store.Update(ctx, key, func(rec Record) Record {
+		rec = g.expire(rec, now)
+		if wait = g.wait(rec, now); wait > 0 {
+			return rec
+		}
+		return g.fail(rec, now)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return wait, nil
+}
+
+// Refund takes back one attempt counted by Attempt without forgetting the
+// others. A lockout stays if the remaining failures still call for one.
+func (g *Guard) Refund(ctx context.Context, key string) error {
+	_, err := g.store.Update(ctx, key, func(rec Record) Record {
+		if rec.Failures == 0 {
+			return rec
+		}
+		rec.Failures--
+		if rec.Failures < g.policy.LockoutAfter {
+			rec.LockedUntil = time.Time{}
+		}
+		return rec
+	})
+	return err
+}
+
+// Succeed forgets the failures recorded for key.
+func (g *Guard) Succeed(ctx context.Context, key string) error {
+	return g.store.Delete(ctx, key)
+}
+
+func (g *Guard) fail(rec Record, now time.Time) Record {
+	rec.Failures++
+	rec.LastFailure = now
+	if g.policy.LockoutAfter > 0 && rec.Failures >= g.policy.LockoutAfter {
+		rec.LockedUntil = now.Add(g.policy.LockoutFor)
+	}
+	return rec
+}
+
+// wait is how long after now the record allows the next attempt.
+func (g *Guard) wait(rec Record, now time.Time) time.Duration {
+	until := rec.LockedUntil
+	if next := rec.LastFailure.Add(g.delay(rec.Failures)); next.After(until) {
+		until = next
+	}
+	if !until.After(now) {
+		return 0
+	}
+	return until.Sub(now)
+}
+
+// expire drops a record nobody has failed against for ResetAfter, once any
+// lockout is over.
+func (g *Guard) expire(rec Record, now time.Time) Record {
+	if now.Sub(rec.LastFailure) >= g.policy.ResetAfter && !rec.LockedUntil.After(now) {
+		return Record{}
+	}
+	return rec
+}
+
+func (g *Guard) delay(failures int) time.Duration {
+	extra := failures - g.policy.FreeAttempts
+	if extra <= 0 {
+		return 0
+	}
+	d := g.policy.BaseDelay
+	for i := 1; i < extra && d < g.policy.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, g.policy.MaxDelay)
+}
@@ -0,0 +1,160 @@
+package loginguard
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts: 3,
+	BaseDelay:    time.Second,
+	MaxDelay:     8 * time.Second,
+	LockoutAfter: 10,
+	LockoutFor:   15 * time.Minute,
+	ResetAfter:   time.Hour,
+}
+
+func newTestGuard() (*Guard, *time.Time) {
+	now := time.Unix(1_700_000_000, 0)
+	g := New(NewMemoryStore(2*time.Hour), testPolicy)
+	g.now = func() time.Time { return now }
+	return g, &now
+}
+
+func mustAttempt(t *testing.T, g *Guard, key string) time.Duration {
+	t.Helper()
+	wait, err := g.Attempt(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Attempt error: %v", err)
+	}
+	return wait
+}
+
+// mustFail makes n attempts at key that go through, waiting out any delay
+// before each of them.
+func mustFail(t *testing.T, g *Guard, now *time.Time, key string, n int) {
+	t.Helper()
+	for range n {
+		if wait := mustAttempt(t, g, key); wait > 0 {
+			*now = now.Add(wait)
+			if wait := mustAttempt(t, g, key); wait != 0 {
+				t.Fatalf("wait = %s after waiting, want 0", wait)
+			}
+		}
+	}
+}
+
+func TestFreeAttemptsHaveNoDelay(t *testing.T) {
+	g, now := newTestGuard()
+	mustFail(t, g, now, "k", 3)
+	if wait := mustAttempt(t, g, "k"); wait != 0 {
+		t.Fatalf("wait = %s, want 0", wait)
+	}
+	if wait := mustAttempt(t, g, "k"); wait != time.Second {
+		t.Fatalf("wait = %s, want 1s", wait)
+	}
+}
+
+func TestBackoffDoublesAndCaps(t *testing.T) {
+	g, now := newTestGuard()
+	mustFail(t, g, now, "k", 3)
+	for _, want := range []time.Duration{1, 2, 4, 8, 8} {
+		mustFail(t, g, now, "k", 1)
+		if wait := mustAttempt(t, g, "k"); wait != want*time.Second {
+			t.Fatalf("wait = %s, want %s", wait, want*time.Second)
+		}
+	}
+}
+
+func TestWaitShrinksWithTime(t *testing.T) {
+	g, now := newTestGuard()
+	mustFail(t, g, now, "k", 5)
+	*now = now.Add(1500 * time.Millisecond)
+	if wait := mustAttempt(t, g, "k"); wait != 500*time.Millisecond {
+		t.Fatalf("wait = %s, want 500ms", wait)
+	}
+	*now = now.Add(time.Second)
+	if wait := mustAttempt(t, g, "k"); wait != 0 {
+		t.Fatalf("wait = %s, want 0", wait)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	g, now := newTestGuard()
+	mustFail(t, g, now, "k", 10)
+	if wait := mustAttempt(t, g, "k"); wait != 15*time.Minute {
+		t.Fatalf("wait = %s, want 15m", wait)
+	}
+	*now = now.Add(15 * time.Minute)
+	if wait := mustAttempt(t, g, "k"); wait != 0 {
+		t.Fatalf("wait after lockout = %s, want 0", wait)
+	}
+}
+
+func TestSucceedAndResetAfterForget(t *testing.T) {
+	g, now := newTestGuard()
+	mustFail(t, g, now, "a", 6)
+	mustFail(t, g, now, "b", 6)
+	if err := g.Succeed(context.Background(), "a"); err != nil {
+		t.Fatalf("Succeed error: %v", err)
+	}
+	if wait := mustAttempt(t, g, "a"); wait != 0 {
+		t.Fatalf("wait after success = %s, want 0", wait)
+	}
+	*now = now.Add(time.Hour)
+	mustFail(t, g, now, "b", 1)
+	if wait := mustAttempt(t, g, "b"); wait != 0 {
+		t.Fatalf("failures should be forgotten after ResetAfter, wait = %s", wait)
+	}
+}
+
+func TestKeysAreIndependent(t *testing.T) {
+	g, now := newTestGuard()
+	mustFail(t, g, now, "a", 10)
+	if wait := mustAttempt(t, g, "b"); wait != 0 {
+		t.Fatalf("wait = %s, want 0", wait)
+	}
+}
+
+func TestAttemptCountsBeforeTheResult(t *testing.T) {
+	g, _ := newTestGuard()
+	var wg sync.WaitGroup
+	var allowed atomic.Int32
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, err := g.Attempt(context.Background(), "k")
+			if err != nil {
+				t.Errorf("Attempt error: %v", err)
+			}
+			if wait == 0 {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	// The free attempts plus the one that earns the first delay.
+	if n := allowed.Load(); n != 4 {
+		t.Fatalf("allowed = %d, want 4", n)
+	}
+}
+
+func TestRefund(t *testing.T) {
+	g, now := newTestGuard()
+	mustFail(t, g, now, "k", 9)
+	*now = now.Add(time.Minute)
+	if wait := mustAttempt(t, g, "k"); wait != 0 {
+		t.Fatalf("wait = %s, want 0", wait)
+	}
+	if err := g.Refund(context.Background(), "k"); err != nil {
+		t.Fatalf("Refund error: %v", err)
+	}
+	// Back to nine failures: the backoff still applies, the lockout doesn't.
+	if wait := mustAttempt(t, g, "k"); wait != 8*time.Second {
+		t.Fatalf("wait = %s, want 8s", wait)
+	}
+}
@@ -0,0 +1,63 @@
+package loginguard
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps records in process memory. Records untouched for ttl
+// are swept out so the map doesn't grow with every address that ever
+// failed a login.
+type MemoryStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	records   map[string]memoryRecord
+	lastSweep time.Time
+}
+
+type memoryRecord struct {
+	Record
+	touched time.Time
+}
+
+// NewMemoryStore takes the longest a record can matter, which is the
+// policy's ResetAfter plus LockoutFor.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, records: make(map[string]memoryRecord)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[key].Record, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, key string, fn func(Record) Record) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	rec := fn(s.records[key].Record)
+	s.records[key] = memoryRecord{Record: rec, touched: now}
+	return rec, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.ttl {
+		return
+	}
+	for key, rec := range s.records {
+		if now.Sub(rec.touched) >= s.ttl {
+			delete(s.records, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,72 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryRecordsMessages(t *testing.T) {
+	m := &Memory{}
+	msg := Message{To: "user@example.com", Subject: "Hola", Body: "cuerpo"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	sent := m.Sent()
+	if len(sent) != 1 || sent[0] != msg {
+		t.Fatalf("Sent() = %+v, want [%+v]", sent, msg)
+	}
+}
+
+func TestFileWritesMessage(t *testing.T) {
+	dir := t.TempDir()
+	m := &File{Dir: dir, From: "chirpy@example.com"}
+	err := m.Send(context.Background(), Message{To: "user@example.com", Subject: "Reset", Body: "line one\nline two"})
+	if err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("expected one .eml file, got %v (%v)", paths, err)
+	}
+	data, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	for _, want := range []string{"From: chirpy@example.com\r\n", "To: user@example.com\r\n", "Subject: Reset\r\n", "line one\r\nline two"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("message %q does not contain %q", data, want)
+		}
+	}
+}
+
+func TestSMTPGivesUpWhenContextEnds(t *testing.T) {
+	// A server that accepts the connection and never greets.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	m := &SMTP{Addr: ln.Addr().String(), From: "chirpy@example.com"}
+	start := time.Now()
+	if err := m.Send(ctx, Message{To: "user@example.com"}); err == nil {
+		t.Fatal("Send debería fallar con un servidor colgado")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Send took %s, want it to stop at the context deadline", elapsed)
+	}
+}
@@ -0,0 +1,128 @@
+package moderation
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Filter masks banned words in chirp bodies. It is safe for concurrent use,
+// so terms can be added and removed while the server is running.
+type Filter struct {
+	mu    sync.RWMutex
+	terms map[string]struct{}
+}
+
+func NewFilter(terms []string) *Filter {
+	return &Filter{terms: termSet(terms)}
+}
+
+// Replace swaps the whole term list, e.g. after reloading it from the
+// database. Terms that don't normalize are skipped, as in NewFilter.
+func (f *Filter) Replace(terms []string) {
+	set := termSet(terms)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.terms = set
+}
+
+func termSet(terms []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(terms))
+	for _, t := range terms {
+		if n, err := NormalizeTerm(t); err == nil {
+			set[n] = struct{}{}
+		}
+	}
+	return set
+}
+
+// NormalizeTerm lower-cases a term and checks it is a single word, since
+// Clean only ever compares whole words.
+func NormalizeTerm(term string) (string, error) {
+	term = strings.ToLower(strings.TrimSpace(term))
+	if term == "" {
+		return "", errors.New("term is empty")
+	}
+	for _, r := range term {
+		if !isWordRune(r) {
+			return "", errors.New("term must be a single word")
+		}
+	}
+	return term, nil
+}
+
+func (f *Filter) Add(term string) error {
+	n, err := NormalizeTerm(term)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.terms[n] = struct{}{}
+	return nil
+}
+
+// Remove reports whether the term was in the list.
+func (f *Filter) Remove(term string) bool {
+	n, err := NormalizeTerm(term)
+	if err != nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.terms[n]
+	delete(f.terms, n)
+	return ok
+}
+
+func (f *Filter) Terms() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	terms := make([]string, 0, len(f.terms))
+	for t := range f.terms {
+		terms = append(terms, t)
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// Clean replaces every banned word in text with one asterisk per character.
+// Words are runs of letters, digits and combining marks, so punctuation
+// around a word ("Kerfuffle!") does not hide it. Everything else is kept
+// as written.
+func (f *Filter) Clean(text string) string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if len(f.terms) == 0 {
+		return text
+	}
+
+	runes := []rune(text)
+	var b strings.Builder
+	b.Grow(len(text))
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		if _, ok := f.terms[strings.ToLower(word)]; ok {
+			b.WriteString(strings.Repeat("*", j-i))
+		} else {
+			b.WriteString(word)
+		}
+		i = j
+	}
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r)
+}
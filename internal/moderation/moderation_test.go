@@ -0,0 +1,67 @@
+package moderation
+
+import "testing"
+
+func TestClean(t *testing.T) {
+	f := NewFilter([]string{"kerfuffle", "sharbert", "fornax"})
+
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"I had something interesting for breakfast", "I had something interesting for breakfast"},
+		{"I hear Mastodon is better than Chirpy. sharbert I need to migrate", "I hear Mastodon is better than Chirpy. ******** I need to migrate"},
+		{"Kerfuffle!", "*********!"},
+		{"what a (Fornax), really", "what a (******), really"},
+		{"kerfuffles are fine", "kerfuffles are fine"},
+		{"  spacing   is\tkept ", "  spacing   is\tkept "},
+	}
+	for _, c := range cases {
+		if got := f.Clean(c.in); got != c.want {
+			t.Errorf("Clean(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestCleanUnicode(t *testing.T) {
+	f := NewFilter([]string{"ÑOÑO", "café"})
+
+	if got := f.Clean("¡Ñoño! un café."); got != "¡****! un ****." {
+		t.Errorf("Clean() = %q", got)
+	}
+}
+
+func TestAddRemove(t *testing.T) {
+	f := NewFilter(nil)
+
+	if err := f.Add("two words"); err == nil {
+		t.Errorf("Add() debería rechazar términos con espacios")
+	}
+	if err := f.Add("  Blorp "); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	if got := f.Clean("blorp."); got != "*****." {
+		t.Errorf("Clean() = %q after Add", got)
+	}
+	if !f.Remove("BLORP") {
+		t.Errorf("Remove() = false, want true")
+	}
+	if f.Remove("blorp") {
+		t.Errorf("Remove() = true for a term that is gone")
+	}
+	if got := f.Clean("blorp."); got != "blorp." {
+		t.Errorf("Clean() = %q after Remove", got)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	f := NewFilter([]string{"kerfuffle"})
+
+	f.Replace([]string{"Sharbert", "two words"})
+	if got := f.Clean("kerfuffle sharbert"); got != "kerfuffle ********" {
+		t.Errorf("Clean() = %q after Replace", got)
+	}
+	if got := f.Terms(); len(got) != 1 || got[0] != "sharbert" {
+		t.Errorf("Terms() = %v, want [sharbert]", got)
+	}
+}
@@ -0,0 +1,104 @@
+// Package webhook verifies signed webhook deliveries. The sender signs
+// "<timestamp>.<body>" with HMAC-SHA256 and sends the Unix timestamp and the
+// hex signature in headers; deliveries outside the tolerance window are
+// refused so a captured request can't be replayed later.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TimestampHeader = "X-Polka-Timestamp"
+	SignatureHeader = "X-Polka-Signature"
+	// signaturePrefix versions the scheme, as in "v1=<hex>".
+	signaturePrefix = "v1="
+)
+
+var (
+	ErrMissingSignature = errors.New("webhook signature missing")
+	ErrBadTimestamp     = errors.New("webhook timestamp invalid")
+	ErrStaleTimestamp   = errors.New("webhook timestamp outside tolerance")
+	ErrBadSignature     = errors.New("webhook signature does not match")
+)
+
+type Verifier struct {
+	// keys are tried in order. During a rotation both the new and the old
+	// key are accepted, so the sender can switch at any time.
+	keys      [][]byte
+	tolerance time.Duration
+	now       func() time.Time
+}
+
+// NewVerifier accepts signatures made with any of the non-empty keys.
+func NewVerifier(tolerance time.Duration, keys ...string) *Verifier {
+	v := &Verifier{tolerance: tolerance, now: time.Now}
+	for _, k := range keys {
+		if k != "" {
+			v.keys = append(v.keys, []byte(k))
+		}
+	}
+	return v
+}
+
+// Sign returns the signature header value for body sent at t.
+func Sign(key string, t time.Time, body []byte) string {
+	return signaturePrefix + hex.EncodeToString(mac([]byte(key), strconv.FormatInt(t.Unix(), 10), body))
+}
+
+// Verify checks the signature headers against the raw body.
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+	ts := header.Get(TimestampHeader)
+	sigs := header.Values(SignatureHeader)
+	if ts == "" || len(sigs) == 0 || len(v.keys) == 0 {
+		return ErrMissingSignature
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrBadTimestamp
+	}
+	age := v.now().Sub(time.Unix(sec, 0))
+	if age > v.tolerance || age < -v.tolerance {
+		return ErrStaleTimestamp
+	}
+
+	// A sender rotating keys may send one signature per key, either in
+	// separate headers or comma separated.
+	var given [][]byte
+	for _, h := range sigs {
+		for _, s := range strings.Split(h, ",") {
+			s = strings.TrimSpace(s)
+			if !strings.HasPrefix(s, signaturePrefix) {
+				continue
+			}
+			b, err := hex.DecodeString(strings.TrimPrefix(s, signaturePrefix))
+			if err == nil {
+				given = append(given, b)
+			}
+		}
+	}
+	for _, key := range v.keys {
+		want := mac(key, ts, body)
+		for _, g := range given {
+			if hmac.Equal(want, g) {
+				return nil
+			}
+		}
+	}
+	return ErrBadSignature
+}
+
+func mac(key []byte, ts string, body []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
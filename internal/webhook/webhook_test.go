@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedHeader(key string, t time.Time, body []byte) http.Header {
+	h := http.Header{}
+	h.Set(TimestampHeader, strconv.FormatInt(t.Unix(), 10))
+	h.Set(SignatureHeader, Sign(key, t, body))
+	return h
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"event":"user.upgraded"}`)
+	v := NewVerifier(5*time.Minute, "nueva", "vieja")
+	v.now = func() time.Time { return now }
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   []byte
+		want   error
+	}{
+		{"clave actual", signedHeader("nueva", now, body), body, nil},
+		{"clave anterior", signedHeader("vieja", now, body), body, nil},
+		{"dentro de la tolerancia", signedHeader("nueva", now.Add(-4*time.Minute), body), body, nil},
+		{"clave desconocida", signedHeader("otra", now, body), body, ErrBadSignature},
+		{"cuerpo alterado", signedHeader("nueva", now, body), []byte(`{"event":"x"}`), ErrBadSignature},
+		{"timestamp viejo", signedHeader("nueva", now.Add(-6*time.Minute), body), body, ErrStaleTimestamp},
+		{"timestamp futuro", signedHeader("nueva", now.Add(6*time.Minute), body), body, ErrStaleTimestamp},
+		{"sin cabeceras", http.Header{}, body, ErrMissingSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v.Verify(tt.header, tt.body); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySeveralSignatures(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte("{}")
+	v := NewVerifier(time.Minute, "vieja")
+	v.now = func() time.Time { return now }
+
+	h := signedHeader("nueva", now, body)
+	h.Set(SignatureHeader, h.Get(SignatureHeader)+", "+Sign("vieja", now, body))
+	if err := v.Verify(h, body); err != nil {
+		t.Errorf("Verify() = %v, want nil", err)
+	}
+}
+
+func TestVerifyWithoutKeys(t *testing.T) {
+	now := time.Now()
+	body := []byte("{}")
+	v := NewVerifier(time.Minute, "")
+	if err := v.Verify(signedHeader("", now, body), body); !errors.Is(err, ErrMissingSignature) {
+		t.Errorf("Verify() = %v, want ErrMissingSignature", err)
+	}
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -13,21 +15,45 @@ import (
 
 	"github.com/SoulOppen/chirpy_go_server/internal/auth"
 	"github.com/SoulOppen/chirpy_go_server/internal/database"
+	"github.com/SoulOppen/chirpy_go_server/internal/loginguard"
+	"github.com/SoulOppen/chirpy_go_server/internal/mailer"
+	"github.com/SoulOppen/chirpy_go_server/internal/moderation"
+	"github.com/SoulOppen/chirpy_go_server/internal/webhook"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	db             *database.Queries
-	secret         string
-	polkaKey       string
+	fileserverHits    atomic.Int32
+	db                *database.Queries
+	sqlDB             *sql.DB
+	jwtKeys           *auth.KeySet
+	accessTokenTTL    time.Duration
+	refreshTokenTTL   time.Duration
+	polkaWebhooks     *webhook.Verifier
+	moderation        *moderation.Filter
+	restoreWindow     time.Duration
+	dupWindow         time.Duration
+	mailer            mailer.Mailer
+	resetTokenTTL     time.Duration
+	verifyTokenTTL    time.Duration
+	requireVerified   bool
+	baseURL           string
+	totpBox           *auth.SecretBox
+	emailGuard        *loginguard.Guard
+	ipGuard           *loginguard.Guard
+	resetEmailGuard   *loginguard.Guard
+	resetIPGuard      *loginguard.Guard
+	resetSlots        chan struct{}
+	dummyPasswordHash string
+	platform          string
 }
 
 type parameters struct {
-	Body   string    `json:"body"`
-	UserId uuid.UUID `json:"user_id"`
+	Body      string     `json:"body"`
+	UserId    uuid.UUID  `json:"user_id"`
+	InReplyTo *uuid.UUID `json:"in_reply_to"`
 }
 
 type mail struct {
@@ -36,11 +62,17 @@ type mail struct {
 }
 
 type User struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Email          string    `json:"email"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+	// EmailVerified and PendingEmail are only set for the user's own account.
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+	PendingEmail  string `json:"pending_email,omitempty"`
+	Role          string `json:"role,omitempty"`
 }
 type param struct {
 	User         User   `json:"user"`
@@ -48,19 +80,105 @@ type param struct {
 	RefreshToken string `json:"refresh_token"`
 }
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserId    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserId    uuid.UUID  `json:"user_id"`
+	InReplyTo *uuid.UUID `json:"in_reply_to,omitempty"`
+	Edited    bool       `json:"edited"`
+	LikeCount int64      `json:"like_count"`
+	LikedByMe bool       `json:"liked_by_me"`
 }
 
 func main() {
 	godotenv.Load()
 
 	dbURL := os.Getenv("DB_URL")
-	secret := os.Getenv("SECRET_STRING")
-	polkaKey := os.Getenv("POLKA_KEY")
+	jwtKeysDir := os.Getenv("JWT_KEYS_DIR")
+	accessTokenTTL, err := envDurationInRange("ACCESS_TOKEN_TTL", time.Hour, time.Minute, 24*time.Hour)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+	refreshTokenTTL, err := envDurationInRange("REFRESH_TOKEN_TTL", 7*24*time.Hour, time.Hour, 90*24*time.Hour)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+	// POLKA_KEY_PREVIOUS keeps the old key valid while Polka switches over.
+	polkaTolerance, err := envDurationInRange("POLKA_SIGNATURE_TOLERANCE", 5*time.Minute, 30*time.Second, time.Hour)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+	polkaWebhooks := webhook.NewVerifier(polkaTolerance, os.Getenv("POLKA_KEY"), os.Getenv("POLKA_KEY_PREVIOUS"))
+	restoreWindow, err := envDuration("CHIRP_RESTORE_WINDOW", 10*time.Minute)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+	dupWindow, err := envDuration("CHIRP_DUPLICATE_WINDOW", 5*time.Minute)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+	keyRotation, err := envDuration("JWT_ROTATION_INTERVAL", 24*time.Hour)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+	resetTokenTTL, err := envDurationInRange("PASSWORD_RESET_TTL", time.Hour, 5*time.Minute, 24*time.Hour)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+	verifyTokenTTL, err := envDurationInRange("EMAIL_VERIFICATION_TTL", 24*time.Hour, 10*time.Minute, 7*24*time.Hour)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+	requireVerified, err := envBool("REQUIRE_VERIFIED_EMAIL", false)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+	passwordParams, err := argon2Params()
+	if err == nil {
+		err = auth.SetArgon2Params(passwordParams)
+	}
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+	// TOTP secrets are sealed with this key; 2FA is unavailable without it.
+	var totpBox *auth.SecretBox
+	if s := os.Getenv("TOTP_ENCRYPTION_KEY"); s != "" {
+		key, err := base64.StdEncoding.DecodeString(s)
+		if err == nil {
+			totpBox, err = auth.NewSecretBox(key)
+		}
+		if err != nil {
+			fmt.Printf("TOTP_ENCRYPTION_KEY must be 32 bytes, base64 encoded: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	baseURL := strings.TrimSuffix(os.Getenv("APP_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	// Without a directory the keys only live in memory, so a restart
+	// invalidates outstanding access tokens and clients must refresh.
+	var jwtKeys *auth.KeySet
+	if jwtKeysDir != "" {
+		jwtKeys, err = auth.LoadKeySet(jwtKeysDir)
+	} else {
+		jwtKeys, err = auth.NewKeySet()
+	}
+	if err != nil {
+		fmt.Printf("Error loading JWT keys: %s\n", err)
+		os.Exit(1)
+	}
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		fmt.Printf("%s\n", err)
@@ -70,32 +188,117 @@ func main() {
 
 	dbQueries := database.New(db)
 
+	bannedWords, err := dbQueries.ListBannedWords(context.Background())
+	if err != nil {
+		fmt.Printf("Error loading banned words: %s\n", err)
+		os.Exit(1)
+	}
+
 	var apiCfg apiConfig
 	apiCfg.db = dbQueries
-	apiCfg.secret = secret
-	apiCfg.polkaKey = polkaKey
+	apiCfg.sqlDB = db
+	jwtKeys.SetPublishDelay(jwksMaxAge)
+	apiCfg.jwtKeys = jwtKeys
+	apiCfg.accessTokenTTL = accessTokenTTL
+	apiCfg.refreshTokenTTL = refreshTokenTTL
+	apiCfg.polkaWebhooks = polkaWebhooks
+	apiCfg.moderation = moderation.NewFilter(bannedWords)
+	apiCfg.restoreWindow = restoreWindow
+	apiCfg.dupWindow = dupWindow
+	mailSender, err := newMailer(os.Getenv("PLATFORM"))
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+	apiCfg.mailer = mailSender
+	apiCfg.resetTokenTTL = resetTokenTTL
+	apiCfg.verifyTokenTTL = verifyTokenTTL
+	apiCfg.requireVerified = requireVerified
+	apiCfg.baseURL = baseURL
+	apiCfg.totpBox = totpBox
+	apiCfg.platform = os.Getenv("PLATFORM")
+	guardStore := loginguard.NewMemoryStore(emailLoginPolicy.ResetAfter + emailLoginPolicy.LockoutFor)
+	apiCfg.emailGuard = loginguard.New(guardStore, emailLoginPolicy)
+	apiCfg.ipGuard = loginguard.New(guardStore, ipLoginPolicy)
+	apiCfg.resetEmailGuard = loginguard.New(guardStore, resetEmailPolicy)
+	apiCfg.resetIPGuard = loginguard.New(guardStore, resetIPPolicy)
+	apiCfg.resetSlots = make(chan struct{}, maxPendingResets)
+	apiCfg.dummyPasswordHash, err = auth.HashPassword(uuid.NewString())
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+
+	go apiCfg.purgeDeletedChirps(context.Background(), time.Minute)
+	go apiCfg.rotateSigningKeys(context.Background(), keyRotation)
+	go apiCfg.reloadBannedWords(context.Background(), 10*time.Second)
+
 	mux := http.NewServeMux()
+	requireAuth := func(h http.HandlerFunc) http.HandlerFunc { return auth.RequireAuth(&apiCfg, h) }
+	optionalAuth := func(h http.HandlerFunc) http.HandlerFunc { return auth.OptionalAuth(&apiCfg, h) }
+	// Personal access tokens reach only the routes granted by their scopes;
+	// account management and admin routes need a login session.
+	requireScope := func(scope string, h http.HandlerFunc) http.HandlerFunc {
+		return requireAuth(auth.RequireScope(scope, h))
+	}
+	requireSession := func(h http.HandlerFunc) http.HandlerFunc { return requireAuth(auth.RequireSession(h)) }
+	requireAdmin := func(h http.HandlerFunc) http.HandlerFunc {
+		return requireSession(auth.RequireRole(auth.RoleAdmin, h))
+	}
 	fileServer := http.FileServer(http.Dir("."))
 
 	mux.Handle("/app/", http.StripPrefix("/app/", apiCfg.middlewareMetricsInc(fileServer)))
 	mux.Handle("/app/assets", http.StripPrefix("/app/", http.FileServer(http.Dir("."))))
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerPrint)
+	mux.HandleFunc("GET /admin/metrics", requireAdmin(apiCfg.handlerPrint))
+	mux.HandleFunc("PUT /admin/users/{userID}/role", requireAdmin(apiCfg.handlerSetRole))
+	mux.HandleFunc("POST /admin/jwks/rotate", requireAdmin(apiCfg.handlerRotateKeys))
+	mux.HandleFunc("GET /admin/webhooks/events", requireAdmin(apiCfg.handlerListWebhookEvents))
+	mux.HandleFunc("POST /admin/webhooks/events/{eventID}/replay", requireAdmin(apiCfg.handlerReplayWebhookEvent))
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerJWKS)
 	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("200 OK"))
 	})
-	mux.HandleFunc("GET /api/chirps", apiCfg.handleGetChirps)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handleGetOneChirp)
+	mux.HandleFunc("GET /api/chirps", optionalAuth(apiCfg.handleGetChirps))
+	mux.HandleFunc("GET /api/chirps/search", optionalAuth(apiCfg.handlerSearchChirps))
+	mux.HandleFunc("GET /api/chirps/{chirpID}", optionalAuth(apiCfg.handleGetOneChirp))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", optionalAuth(apiCfg.handlerGetThread))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", requireScope(auth.ScopeChirpsWrite, apiCfg.handlerLikeChirp))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", requireScope(auth.ScopeChirpsWrite, apiCfg.handlerUnlikeChirp))
 	mux.HandleFunc("POST /api/users", apiCfg.newUser)
-	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerValid)
+	mux.HandleFunc("POST /admin/reset", requireAdmin(apiCfg.handlerReset))
+	mux.HandleFunc("GET /admin/moderation/terms", requireAdmin(apiCfg.handlerListTerms))
+	mux.HandleFunc("POST /admin/moderation/terms", requireAdmin(apiCfg.handlerAddTerm))
+	mux.HandleFunc("DELETE /admin/moderation/terms/{term}", requireAdmin(apiCfg.handlerRemoveTerm))
+	mux.HandleFunc("POST /api/chirps", requireScope(auth.ScopeChirpsWrite, apiCfg.handlerValid))
 	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
+	mux.HandleFunc("POST /api/login/2fa", apiCfg.handlerLoginTwoFactor)
+	mux.HandleFunc("POST /api/2fa/enroll", requireSession(apiCfg.handlerEnrollTOTP))
+	mux.HandleFunc("POST /api/2fa/confirm", requireSession(apiCfg.handlerConfirmTOTP))
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
+	mux.HandleFunc("GET /api/sessions", requireSession(apiCfg.handlerListSessions))
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", requireSession(apiCfg.handlerRevokeSession))
+	mux.HandleFunc("POST /api/sessions/revoke-all", requireSession(apiCfg.handlerRevokeAllSessions))
+	mux.HandleFunc("POST /api/tokens", requireSession(apiCfg.handlerCreateToken))
+	mux.HandleFunc("GET /api/tokens", requireSession(apiCfg.handlerListTokens))
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", requireSession(apiCfg.handlerRevokeToken))
+	mux.HandleFunc("POST /api/password/forgot", apiCfg.handlerForgotPassword)
+	mux.HandleFunc("POST /api/password/reset", apiCfg.handlerResetPassword)
+	mux.HandleFunc("GET /api/verify", apiCfg.handlerVerifyEmail)
+	mux.HandleFunc("POST /api/verify/resend", requireSession(apiCfg.handlerResendVerification))
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerHook)
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdate)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDelete)
+	mux.HandleFunc("PUT /api/users", requireSession(apiCfg.handlerUpdate))
+	mux.HandleFunc("POST /api/users/{userID}/follow", requireScope(auth.ScopeUsersWrite, apiCfg.handlerFollow))
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", requireScope(auth.ScopeUsersWrite, apiCfg.handlerUnfollow))
+	mux.HandleFunc("GET /api/users/{userID}/followers", apiCfg.handlerGetFollowers)
+	mux.HandleFunc("GET /api/users/{userID}/following", apiCfg.handlerGetFollowing)
+	mux.HandleFunc("GET /api/timeline", requireScope(auth.ScopeChirpsRead, apiCfg.handlerTimeline))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", requireScope(auth.ScopeChirpsWrite, apiCfg.handlerDelete))
+	mux.HandleFunc("PATCH /api/chirps/{chirpID}", requireScope(auth.ScopeChirpsWrite, apiCfg.handlerEditChirp))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", requireScope(auth.ScopeChirpsWrite, apiCfg.handlerRestoreChirp))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.handlerGetRevisions)
 	server := &http.Server{
 		Addr:    ":8080",
 		Handler: mux,
@@ -111,41 +314,97 @@ func main() {
 func (cfg *apiConfig) handleGetChirps(w http.ResponseWriter, r *http.Request) {
 	stringId := r.URL.Query().Get("author_id")
 	cleanId := strings.TrimSpace(stringId)
-	var dbChirps []database.Chirp
-
-	if cleanId == "" {
-		var err error
-		dbChirps, err = cfg.db.GetChirps(context.Background())
-		if err != nil {
-			respondWithError(w, 500, "Fail to connect to DB")
-			return
-		}
-	} else {
-		var err error
+	var authorID uuid.NullUUID
+	if cleanId != "" {
 		parseId, err := uuid.Parse(cleanId)
 		if err != nil {
-			respondWithError(w, 500, "Fail to connect to Parse")
-		}
-		dbChirps, err = cfg.db.GetChirpsByAuthor(context.Background(), parseId)
-		if err != nil {
-			respondWithError(w, 500, "Fail to connect to DB")
+			respondWithError(w, 400, "author_id is not uuid")
 			return
 		}
+		authorID = uuid.NullUUID{UUID: parseId, Valid: true}
+	}
+
+	sortOrder := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("sort")))
+	if sortOrder == "" {
+		sortOrder = "asc"
+	}
+	if sortOrder != "asc" && sortOrder != "desc" {
+		respondWithError(w, 400, "sort must be asc or desc")
+		return
+	}
+	since, err := parseTimeParam(r.URL.Query(), "since")
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	until, err := parseTimeParam(r.URL.Query(), "until")
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	scope := newPageScope(sortOrder,
+		filterParam("author_id", authorID.UUID.String(), authorID.Valid),
+		filterParam("since", since.Time.Format(time.RFC3339Nano), since.Valid),
+		filterParam("until", until.Time.Format(time.RFC3339Nano), until.Valid),
+	)
+	limit, cursor, err := parsePageParams(r.URL.Query(), scope)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	var cursorCreatedAt sql.NullTime
+	var cursorID uuid.NullUUID
+	if cursor != nil {
+		cursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		cursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	// Paging backwards walks the opposite order; newChirpPage flips it back.
+	ascending := sortOrder == "asc"
+	if cursor != nil && cursor.Direction == cursorPrev {
+		ascending = !ascending
+	}
+
+	var dbChirps []database.Chirp
+	if ascending {
+		dbChirps, err = cfg.db.GetChirpsPageAsc(r.Context(), database.GetChirpsPageAscParams{
+			AuthorID:        authorID,
+			Since:           since,
+			Until:           until,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       limit + 1,
+		})
+	} else {
+		dbChirps, err = cfg.db.GetChirpsPageDesc(r.Context(), database.GetChirpsPageDescParams{
+			AuthorID:        authorID,
+			Since:           since,
+			Until:           until,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       limit + 1,
+		})
+	}
+	if err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
 	}
 
 	// Mapear a nuestro struct con tags JSON correctos
 	chirps := make([]Chirp, len(dbChirps))
+	refs := make([]*Chirp, len(dbChirps))
 	for i, c := range dbChirps {
-		chirps[i] = Chirp{
-			ID:        c.ID,
-			CreatedAt: c.CreatedAt,
-			UpdatedAt: c.UpdatedAt,
-			Body:      c.Body,
-			UserId:    c.UserID,
-		}
+		chirps[i] = chirpFromDB(c)
+		refs[i] = &chirps[i]
+	}
+	if err := cfg.attachLikes(r.Context(), refs, cfg.viewerID(r)); err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
 	}
 
-	respondWithJSON(w, 200, chirps)
+	respondWithJSON(w, 200, newChirpPage(chirps, limit, cursor, scope))
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -169,6 +428,11 @@ func (cfg *apiConfig) handlerPrint(w http.ResponseWriter, r *http.Request) {
 }
 
 func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
+	// Wiping every user is only ever wanted on a development database.
+	if cfg.platform != "dev" {
+		respondWithError(w, 403, "Reset is only allowed in dev")
+		return
+	}
 	cfg.fileserverHits.Store(0)
 	err := cfg.db.Reset(r.Context())
 	if err != nil {
@@ -183,63 +447,96 @@ func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 
 // POST /api/chirps
 func (cfg *apiConfig) handlerValid(w http.ResponseWriter, r *http.Request) {
-	tokenString, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, 401, "Error")
-		return
-	}
-
-	userID, err := auth.ValidateJWT(tokenString, cfg.secret)
-	if err != nil {
-		respondWithError(w, 401, "error")
+	userID := auth.MustUserID(r.Context())
+	if !cfg.requireVerifiedEmail(w, r, userID) {
 		return
 	}
 	decoder := json.NewDecoder(r.Body)
 	body := parameters{}
-	err = decoder.Decode(&body)
+	err := decoder.Decode(&body)
 	if err != nil {
 		respondWithError(w, 500, "Invalid JSON body")
 		return
 	}
 
-	if len(body.Body) > 140 {
-		respondWithError(w, 400, "Chirp is too long")
+	cleaned, err := cfg.cleanChirpBody(body.Body)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
 		return
 	}
 
-	msg := body.Body
-	badWords := []string{"kerfuffle", "sharbert", "fornax"}
-	words := strings.Fields(msg)
-
-	for i, w := range words {
-		lw := strings.ToLower(w)
-		for _, b := range badWords {
-			if lw == b {
-				words[i] = strings.Repeat("*", 4)
-				break
-			}
+	var inReplyTo uuid.NullUUID
+	if body.InReplyTo != nil {
+		parent, err := cfg.db.OneChirps(r.Context(), *body.InReplyTo)
+		if err != nil {
+			respondWithError(w, 404, "Parent chirp not found")
+			return
 		}
+		inReplyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
 	}
 
 	newChirp := database.InsertChirpsParams{
-		Body:   strings.Join(words, " "),
-		UserID: userID,
+		Body:      cleaned,
+		UserID:    userID,
+		InReplyTo: inReplyTo,
 	}
 
-	chirp, err := cfg.db.InsertChirps(context.Background(), newChirp)
+	var chirp database.Chirp
+	err = cfg.inTx(r.Context(), func(q *database.Queries) error {
+		if err := cfg.checkDuplicateChirp(r.Context(), q, userID, cleaned, uuid.NullUUID{}); err != nil {
+			return err
+		}
+		chirp, err = q.InsertChirps(r.Context(), newChirp)
+		return err
+	})
+	if errors.Is(err, errDuplicateChirp) {
+		respondDuplicateChirp(w)
+		return
+	}
 	if err != nil {
 		fmt.Printf("Error inserting chirp: %v\n", err)
-		respondWithError(w, 401, "Could not insert chirp")
+		respondWithError(w, 500, "Could not insert chirp")
 		return
 	}
 
-	respondWithJSON(w, 201, Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserId:    chirp.UserID,
+	respondWithJSON(w, 201, chirpFromDB(chirp))
+}
+
+var errDuplicateChirp = errors.New("duplicate chirp")
+
+// checkDuplicateChirp returns errDuplicateChirp if the author posted body
+// within the duplicate window, not counting the chirp being edited. It must
+// run in a transaction: it takes a per-author lock held until the end of it,
+// so concurrent posts by one author are checked one after the other.
+func (cfg *apiConfig) checkDuplicateChirp(ctx context.Context, q *database.Queries, userID uuid.UUID, body string, editing uuid.NullUUID) error {
+	if err := q.LockAuthorChirps(ctx, userID); err != nil {
+		return err
+	}
+	duplicate, err := q.HasRecentDuplicate(ctx, database.HasRecentDuplicateParams{
+		UserID:        userID,
+		Body:          body,
+		WindowSeconds: cfg.dupWindow.Seconds(),
+		ExcludeID:     editing,
 	})
+	if err != nil {
+		return err
+	}
+	if duplicate {
+		return errDuplicateChirp
+	}
+	return nil
+}
+
+func respondDuplicateChirp(w http.ResponseWriter) {
+	respondWithErrorCode(w, 409, "duplicate_chirp", "You already posted this chirp recently")
+}
+
+// cleanChirpBody validates a chirp body and masks banned words in it.
+func (cfg *apiConfig) cleanChirpBody(body string) (string, error) {
+	if len(body) > 140 {
+		return "", errors.New("Chirp is too long")
+	}
+	return cfg.moderation.Clean(body), nil
 }
 
 // POST /api/users
@@ -251,24 +548,27 @@ func (cfg *apiConfig) newUser(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, 500, "Invalid JSON body")
 		return
 	}
+	email, err := parseEmail(inputMail.Email)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
 	hashPass, err := auth.HashPassword(inputMail.Password)
 	if err != nil {
 		fmt.Println(err)
 	}
-	user, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{Email: inputMail.Email, HashedPassword: hashPass})
+	user, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{Email: email, HashedPassword: hashPass})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create user")
 		return
 	}
-	userNew := User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed.Bool,
+	// The account exists either way; a failed send can be retried through
+	// POST /api/verify/resend.
+	if err := cfg.sendVerification(r.Context(), user.ID, user.Email); err != nil {
+		fmt.Printf("Error creating verification token: %v\n", err)
 	}
 
-	respondWithJSON(w, 201, userNew)
+	respondWithJSON(w, 201, userFromDB(user))
 }
 func (cfg *apiConfig) handleGetOneChirp(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("chirpID")
@@ -282,46 +582,122 @@ func (cfg *apiConfig) handleGetOneChirp(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, 404, "Bad id")
 		return
 	}
-	respondWithJSON(w, 200, Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		UserId:    chirp.UserID,
-		Body:      chirp.Body,
-	})
+	resp := chirpFromDB(chirp)
+	if err := cfg.attachLikes(r.Context(), []*Chirp{&resp}, cfg.viewerID(r)); err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
+	}
+	respondWithJSON(w, 200, resp)
 }
 func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
-	var inputMail mail
+	type loginParams struct {
+		Password         string `json:"password"`
+		Email            string `json:"email"`
+		ExpiresInSeconds int    `json:"expires_in_seconds"`
+	}
+	var inputMail loginParams
 	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&inputMail)
 	if err != nil {
 		respondWithError(w, 401, "error")
+		return
 	}
-	CheckPassword, err := cfg.db.ReturnHashPassword(context.Background(), inputMail.Email)
+	wait, err := cfg.reserveLoginAttempt(r.Context(), inputMail.Email, r)
 	if err != nil {
-		respondWithError(w, 401, "erorr")
+		respondWithError(w, 500, "error")
+		return
 	}
-	err = auth.CheckPasswordHash(inputMail.Password, CheckPassword)
+	if wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return
+	}
+	// An unknown email is checked against a dummy hash, so it takes as long
+	// and answers the same as a wrong password.
+	CheckPassword, err := cfg.db.ReturnHashPassword(context.Background(), inputMail.Email)
 	if err != nil {
-		respondWithError(w, 401, "error")
+		CheckPassword = cfg.dummyPasswordHash
+	}
+	// The attempt already counts as a failure; it is only taken back on
+	// success.
+	if auth.CheckPasswordHash(inputMail.Password, CheckPassword) != nil || err != nil {
+		respondWithError(w, 401, "Incorrect email or password")
+		return
 	}
 	noPass, err := cfg.db.ReturnUserNotPassword(context.Background(), inputMail.Email)
 	if err != nil {
-		respondWithError(w, 401, "error")
+		respondWithError(w, 401, "Incorrect email or password")
+		return
 	}
-	tokenStr, err := auth.MakeJWT(noPass.ID, cfg.secret, 3600)
+	// The password is only known here, so this is where old hashes are
+	// upgraded. Failing to do so doesn't stop the login.
+	if auth.NeedsRehash(CheckPassword) {
+		if err := cfg.rehashPassword(r.Context(), noPass.ID, inputMail.Password); err != nil {
+			fmt.Printf("Error rehashing password: %v\n", err)
+		}
+	}
+	enabled, err := cfg.twoFactorEnabled(r.Context(), noPass.ID)
+	if err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
+	}
+	// With 2FA on, the account's failures are only cleared once the code is
+	// right too; the address gets its attempt back now.
+	if enabled {
+		if err := cfg.ipGuard.Refund(r.Context(), ipGuardKey(r)); err != nil {
+			respondWithError(w, 500, "error")
+			return
+		}
+		cfg.startLoginChallenge(w, r, noPass.ID)
+		return
+	}
+	if err := cfg.loginSucceeded(r.Context(), noPass.Email, r); err != nil {
+		respondWithError(w, 500, "error")
+		return
+	}
+	cfg.completeLogin(w, r, User{
+		ID:          noPass.ID,
+		CreatedAt:   noPass.CreatedAt,
+		UpdatedAt:   noPass.UpdatedAt,
+		Email:       noPass.Email,
+		IsChirpyRed: noPass.IsChirpyRed.Bool,
+		Role:        noPass.Role,
+	}, inputMail.ExpiresInSeconds)
+}
+
+func (cfg *apiConfig) rehashPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return cfg.db.UpdatePassword(ctx, database.UpdatePasswordParams{
+		ID:             userID,
+		HashedPassword: hashed,
+	})
+}
+
+// completeLogin issues the access and refresh tokens for a user whose
+// credentials have all been checked.
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, r *http.Request, user User, expiresInSeconds int) {
+	// Clients may ask for a shorter-lived token, never a longer one.
+	expiresIn := cfg.accessTokenTTL
+	if requested := time.Duration(expiresInSeconds) * time.Second; requested > 0 && requested < expiresIn {
+		expiresIn = requested
+	}
+	tokenStr, err := auth.MakeJWT(user.ID, user.Role, cfg.jwtKeys, expiresIn)
 	if err != nil {
 		respondWithError(w, 401, "error")
+		return
 	}
 	refreshTokenStr, err := auth.MakeRefreshToken()
 	if err != nil {
 		respondWithError(w, 401, "error")
+		return
 	}
-	expiresAt := time.Now().Add(7 * 24 * time.Hour)
+	expiresAt := time.Now().Add(cfg.refreshTokenTTL)
 
-	rt, err := cfg.db.RefreshToken(context.Background(), database.RefreshTokenParams{
-		Token:  refreshTokenStr,
-		UserID: noPass.ID,
+	_, err = cfg.db.RefreshToken(context.Background(), database.RefreshTokenParams{
+		TokenHash: auth.HashToken(refreshTokenStr),
+		UserID:    user.ID,
 		ExpiresAt: sql.NullTime{
 			Time:  expiresAt,
 			Valid: true,
@@ -329,9 +705,14 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		RevokedAt: sql.NullTime{
 			Valid: false,
 		},
+		// Each login starts a new family; rotated tokens inherit it.
+		FamilyID:  uuid.New(),
+		UserAgent: r.UserAgent(),
+		IpAddress: clientIP(r),
 	})
 	if err != nil {
 		respondWithError(w, 401, "error")
+		return
 	}
 	respondWithJSON(w, 200, struct {
 		ID           uuid.UUID `json:"id"`
@@ -342,45 +723,81 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		Token        string    `json:"token"`
 		RefreshToken string    `json:"refresh_token"`
 	}{
-		ID:           noPass.ID,
-		CreatedAt:    noPass.CreatedAt,
-		UpdatedAt:    noPass.UpdatedAt,
-		Email:        noPass.Email,
-		IsChirpyRed:  noPass.IsChirpyRed.Bool,
+		ID:           user.ID,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+		Email:        user.Email,
+		IsChirpyRed:  user.IsChirpyRed,
 		Token:        tokenStr,
-		RefreshToken: rt.Token,
+		RefreshToken: refreshTokenStr,
 	})
 }
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	type response struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 	tokenString, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		respondWithError(w, 401, "Error")
 		return
 	}
-	idUser, err := cfg.db.GetUserFromRefreshToken(context.Background(), tokenString)
+	newRefreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, 500, "Could not create refresh token")
+		return
+	}
+	// Rotation only succeeds for a live token, and replaces it in the same
+	// statement so two requests can't both spend it.
+	rt, err := cfg.db.RotateRefreshToken(context.Background(), database.RotateRefreshTokenParams{
+		NewTokenHash: auth.HashToken(newRefreshToken),
+		OldTokenHash: auth.HashToken(tokenString),
+		ExpiresAt:    time.Now().Add(cfg.refreshTokenTTL),
+		UserAgent:    r.UserAgent(),
+		IpAddress:    clientIP(r),
+	})
+	if err != nil {
+		cfg.detectRefreshTokenReuse(r.Context(), tokenString)
+		respondWithError(w, 401, "Error")
+		return
+	}
+	// The role is read again so a changed role shows up at the next refresh.
+	user, err := cfg.db.GetUserByID(r.Context(), rt.UserID)
 	if err != nil {
 		respondWithError(w, 401, "Error")
 		return
 	}
-	newToken, err := auth.MakeJWT(idUser, cfg.secret, 3600)
+	newToken, err := auth.MakeJWT(rt.UserID, user.Role, cfg.jwtKeys, cfg.accessTokenTTL)
 	if err != nil {
 		respondWithError(w, 401, "Could not create token")
 		return
 	}
 	respondWithJSON(w, http.StatusOK, response{
-		Token: newToken,
+		Token:        newToken,
+		RefreshToken: newRefreshToken,
 	})
 }
+
+// detectRefreshTokenReuse revokes the whole family when a refresh token that
+// was already rotated is presented again: either the client or an attacker
+// holds a stolen copy, and there is no telling which.
+func (cfg *apiConfig) detectRefreshTokenReuse(ctx context.Context, token string) {
+	old, err := cfg.db.GetRefreshToken(ctx, auth.HashToken(token))
+	if err != nil || !old.ReplacedBy.Valid {
+		return
+	}
+	err = cfg.db.RevokeRefreshTokenFamily(ctx, old.FamilyID)
+	if err != nil {
+		fmt.Printf("Error revoking refresh token family %s: %v\n", old.FamilyID, err)
+	}
+}
 func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 	tokenString, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		respondWithError(w, 401, "Error")
 		return
 	}
-	_, err = cfg.db.UpdateRefreshToken(context.Background(), tokenString)
+	_, err = cfg.db.UpdateRefreshToken(context.Background(), auth.HashToken(tokenString))
 	if err != nil {
 		respondWithError(w, 401, "Error")
 		return
@@ -388,23 +805,33 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(204)
 }
 func (cfg *apiConfig) handlerUpdate(w http.ResponseWriter, r *http.Request) {
-	tokenString, err := auth.GetBearerToken(r.Header)
+	userID := auth.MustUserID(r.Context())
+	decoder := json.NewDecoder(r.Body)
+	Email := mail{}
+	err := decoder.Decode(&Email)
 	if err != nil {
-		respondWithError(w, 401, "Error")
+		respondWithError(w, 401, "Couldn't decode parameters")
 		return
 	}
-	userID, err := auth.ValidateJWT(tokenString, cfg.secret)
+	email, err := parseEmail(Email.Email)
 	if err != nil {
-		respondWithError(w, 401, "Couldn't validate JWT")
+		respondWithError(w, 400, err.Error())
 		return
 	}
-	decoder := json.NewDecoder(r.Body)
-	Email := mail{}
-	err = decoder.Decode(&Email)
+	current, err := cfg.db.GetUserByID(r.Context(), userID)
 	if err != nil {
-		respondWithError(w, 401, "Couldn't decode parameters")
+		respondWithError(w, 401, "User not found")
 		return
 	}
+	// A new address only replaces the current one once it is verified.
+	pendingEmail := ""
+	if email != current.Email {
+		if _, err := cfg.db.ReturnUserNotPassword(r.Context(), email); err == nil {
+			respondWithError(w, 409, "Email already in use")
+			return
+		}
+		pendingEmail = email
+	}
 	hashedPassword, err := auth.HashPassword(Email.Password)
 	if err != nil {
 		respondWithError(w, 401, "Couldn't hash password")
@@ -412,102 +839,95 @@ func (cfg *apiConfig) handlerUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 	user, err := cfg.db.UpdateUser(context.Background(), database.UpdateUserParams{
 		ID:             userID,
-		Email:          Email.Email,
+		Email:          current.Email,
 		HashedPassword: hashedPassword,
 	})
 	if err != nil {
 		respondWithError(w, 401, "Error al actualizar")
 		return
 	}
-	respondWithJSON(w, 200, User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed.Bool,
-	})
+	if pendingEmail != "" {
+		if err := cfg.sendVerification(r.Context(), user.ID, pendingEmail); err != nil {
+			respondWithError(w, 500, "Could not create verification token")
+			return
+		}
+	} else {
+		// Keeping the current address cancels any change still pending.
+		err := cfg.db.InvalidateOtherEmailVerificationTokens(r.Context(), database.InvalidateOtherEmailVerificationTokensParams{
+			UserID: user.ID,
+			Email:  user.Email,
+		})
+		if err != nil {
+			respondWithError(w, 500, "Fail to connect to DB")
+			return
+		}
+	}
+	counts, err := cfg.db.GetFollowCounts(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, "Fail to connect to DB")
+		return
+	}
+	resp := userFromDB(user)
+	resp.FollowerCount = counts.FollowerCount
+	resp.FollowingCount = counts.FollowingCount
+	resp.PendingEmail = pendingEmail
+	respondWithJSON(w, 200, resp)
 }
 func (cfg *apiConfig) handlerDelete(w http.ResponseWriter, r *http.Request) {
-	idChirp := r.PathValue("chirpID")
-
-	tokenString, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, 401, "Not token")
+	chirp, ok := cfg.ownedChirp(w, r)
+	if !ok {
 		return
 	}
-
-	idUser, err := auth.ValidateJWT(tokenString, cfg.secret)
+	// The row stays as a tombstone until the restore window has passed and
+	// purgeDeletedChirps removes it.
+	_, err := cfg.db.SoftDeleteChirp(context.Background(), chirp.ID)
 	if err != nil {
-		respondWithError(w, 401, "Couldn't decode parameters")
+		respondWithError(w, 400, "Fail to delete")
 		return
 	}
 
+	w.WriteHeader(204)
+}
+
+// ownedChirp loads the chirp in the path and checks that it belongs to the
+// authenticated caller, writing the error response when it does not.
+func (cfg *apiConfig) ownedChirp(w http.ResponseWriter, r *http.Request) (database.Chirp, bool) {
+	idChirp := r.PathValue("chirpID")
+	idUser := auth.MustUserID(r.Context())
+
 	u, err := uuid.Parse(idChirp)
 	if err != nil {
 		respondWithError(w, 400, "id is not uuid")
-		return
+		return database.Chirp{}, false
 	}
 
 	chirp, err := cfg.db.OneChirps(context.Background(), u)
 	if err != nil {
 		respondWithError(w, 404, "Chirp not found")
-		return
+		return database.Chirp{}, false
 	}
 
 	if chirp.UserID != idUser {
-		respondWithError(w, 403, "Fail to delete no authorize")
-		return
+		respondWithError(w, 403, "Fail to modify no authorize")
+		return database.Chirp{}, false
 	}
-
-	_, err = cfg.db.DeleteChirp(context.Background(), u)
-	if err != nil {
-		respondWithError(w, 400, "Fail to delete")
-		return
-	}
-
-	w.WriteHeader(204)
+	return chirp, true
 }
-func (cfg *apiConfig) handlerHook(w http.ResponseWriter, r *http.Request) {
-
-	type Param struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
-	}
-	apikey, err := auth.GetAPIKey(r.Header)
-	if err != nil {
-		respondWithError(w, 401, "doesn't exist apikey")
-		return
 
-	}
-	if apikey != cfg.polkaKey {
-		respondWithError(w, 401, "not authorize")
-	}
-	var param Param
-	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&param)
-	if err != nil {
-		respondWithError(w, 400, "no se pudo decodificar")
-		return
-	}
-	if param.Event != "user.upgraded" {
-		w.WriteHeader(204)
-		return
-	}
-	u, err := uuid.Parse(param.Data.UserID)
+// inTx runs fn with queries inside one transaction, committed if fn
+// returns nil and rolled back otherwise.
+func (cfg *apiConfig) inTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.sqlDB.BeginTx(ctx, nil)
 	if err != nil {
-		respondWithError(w, 400, "can't convert id")
-		return
+		return err
 	}
-	_, err = cfg.db.UpdateUserIsRed(context.Background(), u)
-	if err != nil {
-		respondWithError(w, 404, "not user id")
-		return
+	defer tx.Rollback()
+	if err := fn(cfg.db.WithTx(tx)); err != nil {
+		return err
 	}
-	w.WriteHeader(204)
-
+	return tx.Commit()
 }
+
 func respondWithError(w http.ResponseWriter, code int, msg string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -515,8 +935,32 @@ func respondWithError(w http.ResponseWriter, code int, msg string) {
 	json.NewEncoder(w).Encode(payload)
 }
 
+// respondWithErrorCode adds a stable, machine-readable code next to the
+// human-readable message.
+func respondWithErrorCode(w http.ResponseWriter, code int, errCode, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	payload := map[string]string{"error": msg, "code": errCode}
+	json.NewEncoder(w).Encode(payload)
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	json.NewEncoder(w).Encode(payload)
 }
+
+func chirpFromDB(c database.Chirp) Chirp {
+	chirp := Chirp{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Body:      c.Body,
+		UserId:    c.UserID,
+		Edited:    c.EditedAt.Valid,
+	}
+	if c.InReplyTo.Valid {
+		chirp.InReplyTo = &c.InReplyTo.UUID
+	}
+	return chirp
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// Direction of a cursor relative to the page it was taken from.
+const (
+	cursorNext = "next"
+	cursorPrev = "prev"
+)
+
+// pageCursor is the keyset position (created_at, id) a page starts after,
+// encoded to clients as an opaque base64 string. Search results also carry
+// their rank, since they are ordered by it first. Sort and Filters record
+// the query the cursor was issued for, see pageScope.
+type pageCursor struct {
+	Rank      float64   `json:"r,omitempty"`
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	Direction string    `json:"d"`
+	Sort      string    `json:"s,omitempty"`
+	Filters   string    `json:"f,omitempty"`
+}
+
+// pageScope is the sort order and filters of a paged query. A cursor only
+// makes sense for the query it came from, so it is rejected anywhere else
+// instead of silently skipping or repeating rows.
+type pageScope struct {
+	sort    string
+	filters string
+}
+
+// newPageScope fingerprints the filters, so cursors stay short whatever
+// the query looks like.
+func newPageScope(sort string, filters ...string) pageScope {
+	h := sha256.Sum256([]byte(strings.Join(filters, "\x00")))
+	return pageScope{sort: sort, filters: base64.RawURLEncoding.EncodeToString(h[:8])}
+}
+
+// cursor returns a cursor at the given position, stamped with the scope.
+func (s pageScope) cursor(createdAt time.Time, id uuid.UUID, direction string) pageCursor {
+	return pageCursor{CreatedAt: createdAt, ID: id, Direction: direction, Sort: s.sort, Filters: s.filters}
+}
+
+// filterParam formats an optional filter for newPageScope.
+func filterParam(name string, value string, valid bool) string {
+	if !valid {
+		return name + "="
+	}
+	return name + "=" + value
+}
+
+func encodeCursor(c pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errors.New("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, errors.New("invalid cursor")
+	}
+	if c.Direction != cursorNext && c.Direction != cursorPrev {
+		return c, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+// parsePageParams reads the limit and cursor query parameters. A nil cursor
+// means the first page. A cursor issued for another scope is an error.
+func parsePageParams(query url.Values, scope pageScope) (int32, *pageCursor, error) {
+	limit, err := parseLimit(query)
+	if err != nil {
+		return 0, nil, err
+	}
+	s := strings.TrimSpace(query.Get("cursor"))
+	if s == "" {
+		return limit, nil, nil
+	}
+	c, err := decodeCursor(s)
+	if err != nil {
+		return 0, nil, err
+	}
+	if c.Sort != scope.sort || c.Filters != scope.filters {
+		return 0, nil, errors.New("cursor does not match the query")
+	}
+	return limit, &c, nil
+}
+
+// parseLimit reads the limit query parameter, capped at maxPageLimit.
+func parseLimit(query url.Values) (int32, error) {
+	limit := defaultPageLimit
+	if s := strings.TrimSpace(query.Get("limit")); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return 0, errors.New("limit must be a positive integer")
+		}
+		limit = min(n, maxPageLimit)
+	}
+	return int32(limit), nil
+}
+
+// parseTimeParam reads an optional RFC 3339 query parameter.
+func parseTimeParam(query url.Values, name string) (sql.NullTime, error) {
+	s := strings.TrimSpace(query.Get(name))
+	if s == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return sql.NullTime{}, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+}
+
+type chirpPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	PrevCursor string  `json:"prev_cursor,omitempty"`
+}
+
+// newChirpPage builds a page from rows fetched with limit+1 in the cursor's
+// direction, so the extra row tells whether there is more beyond the page.
+func newChirpPage(chirps []Chirp, limit int32, cursor *pageCursor, scope pageScope) chirpPage {
+	backward := cursor != nil && cursor.Direction == cursorPrev
+	hasMore := len(chirps) > int(limit)
+	if hasMore {
+		chirps = chirps[:limit]
+	}
+	if backward {
+		for i, j := 0, len(chirps)-1; i < j; i, j = i+1, j-1 {
+			chirps[i], chirps[j] = chirps[j], chirps[i]
+		}
+	}
+
+	page := chirpPage{Chirps: chirps}
+	if len(chirps) == 0 {
+		return page
+	}
+	first, last := chirps[0], chirps[len(chirps)-1]
+	if hasMore || backward {
+		page.NextCursor = encodeCursor(scope.cursor(last.CreatedAt, last.ID, cursorNext))
+	}
+	if (backward && hasMore) || (!backward && cursor != nil) {
+		page.PrevCursor = encodeCursor(scope.cursor(first.CreatedAt, first.ID, cursorPrev))
+	}
+	return page
+}
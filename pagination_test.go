@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testChirps returns n chirps in ascending (created_at, id) order.
+func testChirps(n int) []Chirp {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	chirps := make([]Chirp, n)
+	for i := range chirps {
+		chirps[i] = Chirp{ID: uuid.New(), CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+	}
+	return chirps
+}
+
+func chirpBefore(a, b Chirp) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID.String() < b.ID.String()
+}
+
+// fetchPage does what GetChirpsPageAsc does for sort=asc: limit+1 rows past
+// the cursor, walking backwards for a prev cursor.
+func fetchPage(all []Chirp, cursor *pageCursor, limit int32) []Chirp {
+	var rows []Chirp
+	for _, c := range all {
+		switch {
+		case cursor == nil:
+			rows = append(rows, c)
+		case cursor.Direction == cursorNext && chirpBefore(Chirp{ID: cursor.ID, CreatedAt: cursor.CreatedAt}, c):
+			rows = append(rows, c)
+		case cursor.Direction == cursorPrev && chirpBefore(c, Chirp{ID: cursor.ID, CreatedAt: cursor.CreatedAt}):
+			rows = append(rows, c)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return chirpBefore(rows[i], rows[j]) })
+	if cursor != nil && cursor.Direction == cursorPrev {
+		sort.Slice(rows, func(i, j int) bool { return chirpBefore(rows[j], rows[i]) })
+	}
+	if len(rows) > int(limit)+1 {
+		rows = rows[:limit+1]
+	}
+	return rows
+}
+
+func TestChirpPages(t *testing.T) {
+	all := testChirps(7)
+	scope := newPageScope("asc")
+	at := func(i int, direction string) string {
+		return encodeCursor(scope.cursor(all[i].CreatedAt, all[i].ID, direction))
+	}
+
+	cases := []struct {
+		name     string
+		cursor   string
+		want     []int
+		wantNext string
+		wantPrev string
+	}{
+		{"first page", "", []int{0, 1, 2}, at(2, cursorNext), ""},
+		{"middle page", at(2, cursorNext), []int{3, 4, 5}, at(5, cursorNext), at(3, cursorPrev)},
+		{"last page", at(5, cursorNext), []int{6}, "", at(6, cursorPrev)},
+		{"prev to the first page", at(3, cursorPrev), []int{0, 1, 2}, at(2, cursorNext), ""},
+		{"prev to a middle page", at(6, cursorPrev), []int{3, 4, 5}, at(5, cursorNext), at(3, cursorPrev)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			query := url.Values{"limit": {"3"}}
+			if c.cursor != "" {
+				query.Set("cursor", c.cursor)
+			}
+			limit, cursor, err := parsePageParams(query, scope)
+			if err != nil {
+				t.Fatalf("parsePageParams error: %v", err)
+			}
+
+			page := newChirpPage(fetchPage(all, cursor, limit), limit, cursor, scope)
+			if len(page.Chirps) != len(c.want) {
+				t.Fatalf("got %d chirps, want %d", len(page.Chirps), len(c.want))
+			}
+			for i, idx := range c.want {
+				if page.Chirps[i].ID != all[idx].ID {
+					t.Errorf("chirp %d is not chirp %d", i, idx)
+				}
+			}
+			if page.NextCursor != c.wantNext {
+				t.Errorf("NextCursor = %q, want %q", page.NextCursor, c.wantNext)
+			}
+			if page.PrevCursor != c.wantPrev {
+				t.Errorf("PrevCursor = %q, want %q", page.PrevCursor, c.wantPrev)
+			}
+		})
+	}
+}
+
+func TestParsePageParamsRejectsBadInput(t *testing.T) {
+	scope := newPageScope("asc", filterParam("author_id", "", false))
+	pos := scope.cursor(time.Now(), uuid.New(), cursorNext)
+
+	otherSort := pos
+	otherSort.Sort = "desc"
+	otherFilters := pos
+	otherFilters.Filters = newPageScope("asc", filterParam("author_id", uuid.NewString(), true)).filters
+	badDirection := pos
+	badDirection.Direction = "sideways"
+
+	cases := []struct {
+		name  string
+		query url.Values
+	}{
+		{"zero limit", url.Values{"limit": {"0"}}},
+		{"negative limit", url.Values{"limit": {"-5"}}},
+		{"non-numeric limit", url.Values{"limit": {"ten"}}},
+		{"cursor is not base64", url.Values{"cursor": {"%%%"}}},
+		{"cursor is not JSON", url.Values{"cursor": {base64.RawURLEncoding.EncodeToString([]byte("nope"))}}},
+		{"cursor has an unknown direction", url.Values{"cursor": {encodeCursor(badDirection)}}},
+		{"cursor from another sort", url.Values{"cursor": {encodeCursor(otherSort)}}},
+		{"cursor from other filters", url.Values{"cursor": {encodeCursor(otherFilters)}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, _, err := parsePageParams(c.query, scope); err == nil {
+				t.Errorf("parsePageParams(%v) should fail", c.query)
+			}
+		})
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	cases := []struct {
+		in   string
+		want int32
+	}{
+		{"", defaultPageLimit},
+		{"1", 1},
+		{" 50 ", 50},
+		{"1000", maxPageLimit},
+	}
+	for _, c := range cases {
+		got, err := parseLimit(url.Values{"limit": {c.in}})
+		if err != nil {
+			t.Errorf("parseLimit(%q) error: %v", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("parseLimit(%q) = %d, want %d", c.in, got, c.want)
+		}
+	}
+}
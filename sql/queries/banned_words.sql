@@ -0,0 +1,10 @@
+-- name: ListBannedWords :many
+SELECT term FROM banned_words
+ORDER BY term;
+-- name: AddBannedWord :exec
+INSERT INTO banned_words (term, created_at)
+VALUES ($1, NOW())
+ON CONFLICT (term) DO NOTHING;
+-- name: RemoveBannedWord :execrows
+DELETE FROM banned_words
+WHERE term=$1;
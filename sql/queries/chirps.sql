@@ -1,24 +1,157 @@
 -- name: InsertChirps :one
-INSERT INTO chirps (id,created_at,updated_at,body,user_id) 
+INSERT INTO chirps (id,created_at,updated_at,body,user_id,in_reply_to) 
 VALUES(
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
     )
     RETURNING *;
--- name: GetChirps :many
+-- name: HasRecentDuplicate :one
+SELECT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE user_id = sqlc.arg(user_id)
+    AND body = sqlc.arg(body)
+    AND deleted_at IS NULL
+    AND created_at > NOW() - make_interval(secs => sqlc.arg(window_seconds)::float8)
+    AND (sqlc.narg(exclude_id)::uuid IS NULL OR id <> sqlc.narg(exclude_id)::uuid)
+);
+-- name: LockAuthorChirps :exec
+SELECT pg_advisory_xact_lock(hashtext('chirps:' || sqlc.arg(user_id)::uuid::text));
+-- name: GetChirpsPageAsc :many
 SELECT * FROM chirps
-ORDER BY created_at ASC;
--- name: GetChirpsByAuthor :many
+WHERE deleted_at IS NULL
+AND (sqlc.narg(author_id)::uuid IS NULL OR user_id = sqlc.narg(author_id)::uuid)
+AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since)::timestamp)
+AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until)::timestamp)
+AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_limit);
+-- name: GetChirpsPageDesc :many
 SELECT * FROM chirps
-WHERE user_id=$1
-ORDER BY created_at ASC;
+WHERE deleted_at IS NULL
+AND (sqlc.narg(author_id)::uuid IS NULL OR user_id = sqlc.narg(author_id)::uuid)
+AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since)::timestamp)
+AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until)::timestamp)
+AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_limit);
 -- name: OneChirps :one
 SELECT * FROM chirps
-WHERE id=$1;
--- name: DeleteChirp :one
-DELETE FROM chirps
 WHERE id=$1
-RETURNING *;
\ No newline at end of file
+AND deleted_at IS NULL;
+-- name: SoftDeleteChirp :one
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    deleted_at = NOW()
+WHERE id=$1
+AND deleted_at IS NULL
+RETURNING *;
+-- name: GetDeletedChirp :one
+SELECT * FROM chirps
+WHERE id=$1
+AND deleted_at IS NOT NULL;
+-- name: RestoreChirp :one
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    deleted_at = NULL
+WHERE id = sqlc.arg(id)
+AND deleted_at > NOW() - make_interval(secs => sqlc.arg(window_seconds)::float8)
+RETURNING *;
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < NOW() - make_interval(secs => sqlc.arg(window_seconds)::float8)
+AND NOT EXISTS (
+    SELECT 1 FROM chirps r
+    WHERE r.in_reply_to = chirps.id
+);
+-- name: EditChirp :one
+WITH revision AS (
+    INSERT INTO chirp_revisions (id, chirp_id, body, created_at)
+    SELECT gen_random_uuid(), chirps.id, chirps.body, NOW()
+    FROM chirps
+    WHERE chirps.id = sqlc.arg(id)
+    AND chirps.deleted_at IS NULL
+)
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    edited_at = NOW(),
+    body = sqlc.arg(body)
+WHERE chirps.id = sqlc.arg(id)
+AND chirps.deleted_at IS NULL
+RETURNING *;
+-- name: GetChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id=$1
+ORDER BY created_at ASC;
+-- name: GetThreadRootID :one
+WITH RECURSIVE ancestors AS (
+    SELECT id, in_reply_to FROM chirps WHERE chirps.id = sqlc.arg(chirp_id)
+    UNION ALL
+    SELECT c.id, c.in_reply_to
+    FROM chirps c
+    JOIN ancestors a ON c.id = a.in_reply_to
+)
+SELECT ancestors.id FROM ancestors
+WHERE ancestors.in_reply_to IS NULL;
+-- name: GetThread :many
+WITH RECURSIVE thread AS (
+    SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.in_reply_to, c.edited_at, c.deleted_at, 0 AS depth
+    FROM chirps c
+    WHERE c.id = $1
+    UNION ALL
+    SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.in_reply_to, c.edited_at, c.deleted_at, t.depth + 1
+    FROM chirps c
+    JOIN thread t ON c.in_reply_to = t.id
+)
+SELECT
+    t.id,
+    t.created_at,
+    t.updated_at,
+    CASE WHEN t.deleted_at IS NULL THEN t.body ELSE '' END::text AS body,
+    t.user_id,
+    t.in_reply_to,
+    t.deleted_at IS NOT NULL AS deleted,
+    t.edited_at,
+    t.depth::int AS depth,
+    (SELECT COUNT(*) FROM chirps r WHERE r.in_reply_to = t.id AND r.deleted_at IS NULL) AS reply_count
+FROM thread t
+ORDER BY t.depth, t.created_at, t.id;
+-- name: SearchChirps :many
+SELECT
+    c.id,
+    c.created_at,
+    c.updated_at,
+    c.body,
+    c.user_id,
+    c.in_reply_to,
+    c.edited_at,
+    ts_rank(to_tsvector('english', c.body), websearch_to_tsquery('english', sqlc.arg(query)))::float8 AS rank,
+    ts_headline(
+        'english',
+        replace(replace(replace(c.body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+        websearch_to_tsquery('english', sqlc.arg(query)),
+        'StartSel=<mark>, StopSel=</mark>, MaxFragments=2'
+    )::text AS snippet
+FROM chirps c
+WHERE to_tsvector('english', c.body) @@ websearch_to_tsquery('english', sqlc.arg(query))
+AND c.deleted_at IS NULL
+AND (sqlc.narg(author_id)::uuid IS NULL OR c.user_id = sqlc.narg(author_id)::uuid)
+AND (
+    sqlc.narg(cursor_rank)::float8 IS NULL
+    OR (ts_rank(to_tsvector('english', c.body), websearch_to_tsquery('english', sqlc.arg(query)))::float8, c.created_at, c.id)
+        < (sqlc.narg(cursor_rank)::float8, sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+)
+ORDER BY rank DESC, c.created_at DESC, c.id DESC
+LIMIT sqlc.arg(page_limit);
@@ -0,0 +1,37 @@
+-- name: CreateEmailVerificationToken :exec
+INSERT INTO email_verification_tokens (token_hash, user_id, email, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW(),
+    $4
+);
+-- name: VerifyEmail :one
+WITH consumed AS (
+    UPDATE email_verification_tokens
+    SET used_at = NOW()
+    WHERE email_verification_tokens.token_hash = $1
+    AND email_verification_tokens.used_at IS NULL
+    AND email_verification_tokens.expires_at > NOW()
+    RETURNING email_verification_tokens.user_id, email_verification_tokens.email
+)
+UPDATE users
+SET
+    updated_at = NOW(),
+    email = consumed.email,
+    email_verified_at = NOW()
+FROM consumed
+WHERE users.id = consumed.user_id
+RETURNING users.id, users.created_at, users.updated_at, users.email, users.hashed_password, users.is_chirpy_red, users.email_verified_at, users.role;
+-- name: InvalidateEmailVerificationTokens :exec
+UPDATE email_verification_tokens
+SET used_at = NOW()
+WHERE user_id = $1
+AND used_at IS NULL;
+-- name: InvalidateOtherEmailVerificationTokens :exec
+UPDATE email_verification_tokens
+SET used_at = NOW()
+WHERE user_id = $1
+AND email <> $2
+AND used_at IS NULL;
@@ -0,0 +1,64 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id=$1
+AND followee_id=$2;
+-- name: GetFollowers :many
+SELECT
+    u.id,
+    u.created_at,
+    u.is_chirpy_red,
+    (SELECT COUNT(*) FROM follows f2 WHERE f2.followee_id = u.id) AS follower_count,
+    (SELECT COUNT(*) FROM follows f3 WHERE f3.follower_id = u.id) AS following_count
+FROM follows f
+JOIN users u ON u.id = f.follower_id
+WHERE f.followee_id = $1
+ORDER BY f.created_at DESC, u.id;
+-- name: GetFollowing :many
+SELECT
+    u.id,
+    u.created_at,
+    u.is_chirpy_red,
+    (SELECT COUNT(*) FROM follows f2 WHERE f2.followee_id = u.id) AS follower_count,
+    (SELECT COUNT(*) FROM follows f3 WHERE f3.follower_id = u.id) AS following_count
+FROM follows f
+JOIN users u ON u.id = f.followee_id
+WHERE f.follower_id = $1
+ORDER BY f.created_at DESC, u.id;
+-- name: GetFollowCounts :one
+SELECT
+    (SELECT COUNT(*) FROM follows WHERE followee_id = sqlc.arg(user_id)) AS follower_count,
+    (SELECT COUNT(*) FROM follows WHERE follower_id = sqlc.arg(user_id)) AS following_count;
+-- name: GetTimelinePageAsc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (
+    user_id = sqlc.arg(user_id)
+    OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg(user_id))
+)
+AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_limit);
+-- name: GetTimelinePageDesc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (
+    user_id = sqlc.arg(user_id)
+    OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg(user_id))
+)
+AND (
+    sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_limit);
@@ -0,0 +1,29 @@
+-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    $3
+);
+-- name: ResetPassword :one
+WITH consumed AS (
+    UPDATE password_reset_tokens
+    SET used_at = NOW()
+    WHERE password_reset_tokens.token_hash = sqlc.arg(token_hash)
+    AND password_reset_tokens.used_at IS NULL
+    AND password_reset_tokens.expires_at > NOW()
+    RETURNING password_reset_tokens.user_id
+)
+UPDATE users
+SET
+    updated_at = NOW(),
+    hashed_password = sqlc.arg(hashed_password)
+FROM consumed
+WHERE users.id = consumed.user_id
+RETURNING users.id;
+-- name: InvalidatePasswordResetTokens :exec
+UPDATE password_reset_tokens
+SET used_at = NOW()
+WHERE user_id = $1
+AND used_at IS NULL;
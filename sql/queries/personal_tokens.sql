@@ -0,0 +1,35 @@
+-- name: CreatePersonalToken :one
+INSERT INTO personal_access_tokens (id, user_id, name, token_hash, scopes, created_at, expires_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW(),
+    $5
+)
+RETURNING *;
+-- name: ListPersonalTokens :many
+SELECT * FROM personal_access_tokens
+WHERE user_id = $1
+AND revoked_at IS NULL
+ORDER BY created_at DESC;
+-- name: RevokePersonalToken :execrows
+UPDATE personal_access_tokens
+SET revoked_at = NOW()
+WHERE id = sqlc.arg(id)
+AND user_id = sqlc.arg(user_id)
+AND revoked_at IS NULL;
+-- name: GetPersonalTokenForAuth :one
+SELECT t.id, t.user_id, t.scopes, u.role
+FROM personal_access_tokens t
+JOIN users u ON u.id = t.user_id
+WHERE t.token_hash = $1
+AND t.revoked_at IS NULL
+AND (t.expires_at IS NULL OR t.expires_at > NOW());
+-- name: TouchPersonalToken :exec
+UPDATE personal_access_tokens
+SET last_used_at = NOW()
+WHERE id = $1
+AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
@@ -1,11 +1,15 @@
 -- name: RefreshToken :one
 INSERT INTO refresh_tokens (
-    token,
+    token_hash,
     created_at,
     updated_at,
     user_id,
     expires_at,
-    revoked_at
+    revoked_at,
+    family_id,
+    user_agent,
+    ip_address,
+    last_used_at
     )
 VALUES (
     $1,  
@@ -13,19 +17,89 @@ VALUES (
     NOW(),              
     $2,
     $3,
-    $4  
+    $4,
+    $5,
+    $6,
+    $7,
+    NOW()
 )
 RETURNING *;
--- name: GetUserFromRefreshToken :one
-SELECT user_id
-FROM refresh_tokens
-WHERE token=$1
+-- name: UpdateRefreshToken :one
+UPDATE refresh_tokens
+SET
+  updated_at = NOW(),
+  revoked_at = NOW()
+WHERE token_hash = $1
+RETURNING *;
+-- name: GetRefreshToken :one
+SELECT * FROM refresh_tokens
+WHERE token_hash=$1;
+-- name: RotateRefreshToken :one
+WITH old AS (
+    UPDATE refresh_tokens
+    SET
+        updated_at = NOW(),
+        revoked_at = NOW(),
+        replaced_by = sqlc.arg(new_token_hash)::text
+    WHERE refresh_tokens.token_hash = sqlc.arg(old_token_hash)
+    AND refresh_tokens.revoked_at IS NULL
+    AND refresh_tokens.expires_at > NOW()
+    RETURNING refresh_tokens.user_id, refresh_tokens.family_id
+)
+INSERT INTO refresh_tokens (
+    token_hash,
+    created_at,
+    updated_at,
+    user_id,
+    expires_at,
+    revoked_at,
+    family_id,
+    user_agent,
+    ip_address,
+    last_used_at
+    )
+SELECT
+    sqlc.arg(new_token_hash)::text,
+    NOW(),
+    NOW(),
+    old.user_id,
+    sqlc.arg(expires_at)::timestamp,
+    NULL,
+    old.family_id,
+    sqlc.arg(user_agent)::text,
+    sqlc.arg(ip_address)::text,
+    NOW()
+FROM old
+RETURNING *;
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET
+  updated_at = NOW(),
+  revoked_at = COALESCE(revoked_at, NOW())
+WHERE family_id = $1;
+-- name: ListSessions :many
+SELECT
+    rt.family_id,
+    rt.user_agent,
+    rt.ip_address,
+    (SELECT MIN(f.created_at) FROM refresh_tokens f WHERE f.family_id = rt.family_id)::timestamp AS signed_in_at,
+    rt.last_used_at,
+    rt.expires_at
+FROM refresh_tokens rt
+WHERE rt.user_id = $1
+AND rt.revoked_at IS NULL
+AND rt.expires_at > NOW()
+ORDER BY rt.last_used_at DESC;
+-- name: GetActiveSession :one
+SELECT * FROM refresh_tokens
+WHERE family_id = sqlc.arg(family_id)
+AND user_id = sqlc.arg(user_id)
 AND revoked_at IS NULL
 AND expires_at > NOW();
--- name: UpdateRefreshToken :one
+-- name: RevokeUserRefreshTokens :execrows
 UPDATE refresh_tokens
 SET
   updated_at = NOW(),
   revoked_at = NOW()
-WHERE token = $1
-RETURNING *;
\ No newline at end of file
+WHERE user_id = $1
+AND revoked_at IS NULL;
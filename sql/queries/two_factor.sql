@@ -0,0 +1,65 @@
+-- name: UpsertPendingTOTP :execrows
+INSERT INTO user_totp (user_id, secret_encrypted, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (user_id) DO UPDATE
+SET
+    secret_encrypted = EXCLUDED.secret_encrypted,
+    created_at = NOW(),
+    last_step = 0
+WHERE user_totp.enabled_at IS NULL;
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp
+WHERE user_id=$1;
+-- name: EnableTOTP :execrows
+UPDATE user_totp
+SET
+    enabled_at = NOW(),
+    last_step = sqlc.arg(step)
+WHERE user_id = sqlc.arg(user_id)
+AND enabled_at IS NULL;
+-- name: UseTOTPStep :execrows
+UPDATE user_totp
+SET last_step = sqlc.arg(step)
+WHERE user_id = sqlc.arg(user_id)
+AND enabled_at IS NOT NULL
+AND last_step < sqlc.arg(step);
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM totp_recovery_codes
+WHERE user_id=$1;
+-- name: CreateRecoveryCodes :exec
+INSERT INTO totp_recovery_codes (user_id, code_hash, created_at)
+SELECT sqlc.arg(user_id), unnest(sqlc.arg(code_hashes)::text[]), NOW();
+-- name: UseRecoveryCode :execrows
+UPDATE totp_recovery_codes
+SET used_at = NOW()
+WHERE user_id = sqlc.arg(user_id)
+AND code_hash = sqlc.arg(code_hash)
+AND used_at IS NULL;
+-- name: CreateLoginChallenge :exec
+INSERT INTO login_challenges (token_hash, user_id, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    $3
+);
+-- name: GetLoginChallenge :one
+SELECT * FROM login_challenges
+WHERE token_hash=$1
+AND used_at IS NULL
+AND expires_at > NOW();
+-- name: FailLoginChallenge :exec
+UPDATE login_challenges
+SET attempts = attempts + 1
+WHERE token_hash=$1;
+-- name: ConsumeLoginChallenge :one
+UPDATE login_challenges
+SET used_at = NOW()
+WHERE token_hash=$1
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING user_id;
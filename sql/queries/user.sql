@@ -13,7 +13,7 @@ SELECT hashed_password
 FROM users 
 WHERE email=$1;
 -- name: ReturnUserNotPassword :one
-SELECT id,created_at, updated_at, email,is_chirpy_red
+SELECT id,created_at, updated_at, email,is_chirpy_red,role
 FROM users 
 WHERE email=$1;
 -- name: UpdateUser :one
@@ -31,3 +31,17 @@ SET
     is_chirpy_red= true
 WHERE id = $1
 RETURNING *;
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id=$1;
+-- name: UpdatePassword :exec
+UPDATE users
+SET hashed_password = $2
+WHERE id = $1;
+-- name: UpdateUserRole :one
+UPDATE users
+SET
+    updated_at = NOW(),
+    role = $2
+WHERE id = $1
+RETURNING *;
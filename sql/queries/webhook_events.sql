@@ -0,0 +1,47 @@
+-- Records a delivery. A delivery of an event that is already recorded
+-- returns no row, unless the event failed or its claim went stale, in
+-- which case it is claimed again so the retry is processed.
+-- name: ClaimWebhookEvent :one
+INSERT INTO webhook_events (id, provider, event_id, event_type, payload, received_at, claimed_at)
+VALUES (
+    gen_random_uuid(),
+    sqlc.arg(provider),
+    sqlc.arg(event_id),
+    sqlc.arg(event_type),
+    sqlc.arg(payload),
+    NOW(),
+    NOW()
+)
+ON CONFLICT (provider, event_id) DO UPDATE
+SET
+    status = 'pending',
+    error = NULL,
+    attempts = webhook_events.attempts + 1,
+    claimed_at = NOW()
+WHERE webhook_events.status = 'failed'
+OR (webhook_events.status = 'pending' AND webhook_events.claimed_at < sqlc.arg(stale_before))
+RETURNING *;
+-- name: ReclaimWebhookEvent :one
+UPDATE webhook_events
+SET
+    status = 'pending',
+    error = NULL,
+    attempts = attempts + 1,
+    claimed_at = NOW()
+WHERE id = sqlc.arg(id)
+AND (status = 'failed' OR (status = 'pending' AND claimed_at < sqlc.arg(stale_before)))
+RETURNING *;
+-- name: FinishWebhookEvent :one
+UPDATE webhook_events
+SET
+    status = $2,
+    error = $3,
+    processed_at = NOW()
+WHERE id = $1
+RETURNING *;
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status))
+AND (NOT sqlc.arg(stale_only)::boolean OR (status = 'pending' AND claimed_at < sqlc.arg(stale_before)))
+ORDER BY received_at DESC, id DESC
+LIMIT sqlc.arg(max_rows);
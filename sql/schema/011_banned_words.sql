@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE banned_words (
+    term TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO banned_words (term)
+VALUES ('kerfuffle'), ('sharbert'), ('fornax');
+
+-- +goose Down
+DROP TABLE banned_words;
@@ -0,0 +1,26 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN deleted_at TIMESTAMP DEFAULT NULL;
+
+UPDATE chirps
+SET deleted_at = updated_at
+WHERE deleted;
+
+ALTER TABLE chirps
+DROP COLUMN deleted;
+
+CREATE INDEX chirps_deleted_at_idx ON chirps (deleted_at)
+WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_deleted_at_idx;
+
+ALTER TABLE chirps
+ADD COLUMN deleted BOOL NOT NULL DEFAULT false;
+
+UPDATE chirps
+SET deleted = true
+WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE chirps
+DROP COLUMN deleted_at;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Tokens are stored as the hex SHA-256 of the value handed to clients.
+-- Rows from before this migration held the raw token: replace it with its
+-- digest so the table holds nothing usable, and revoke them so those
+-- sessions have to log in again.
+ALTER TABLE refresh_tokens
+RENAME COLUMN token TO token_hash;
+
+UPDATE refresh_tokens
+SET
+    token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex'),
+    replaced_by = encode(sha256(convert_to(replaced_by, 'UTF8')), 'hex'),
+    updated_at = NOW(),
+    revoked_at = COALESCE(revoked_at, NOW());
+
+-- +goose Down
+-- The raw tokens can't be recovered; the rows stay revoked.
+ALTER TABLE refresh_tokens
+RENAME COLUMN token_hash TO token;
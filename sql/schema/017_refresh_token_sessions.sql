@@ -0,0 +1,26 @@
+-- +goose Up
+ALTER TABLE refresh_tokens
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE refresh_tokens
+ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE refresh_tokens
+ADD COLUMN last_used_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+UPDATE refresh_tokens
+SET last_used_at = COALESCE(updated_at, created_at, NOW());
+
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_user_id_idx;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN last_used_at;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN ip_address;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN user_agent;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Grant the first admin by hand:
+-- UPDATE users SET role = 'admin' WHERE email = '...';
+ALTER TABLE users
+ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+CHECK (role IN ('user', 'moderator', 'admin'));
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN role;
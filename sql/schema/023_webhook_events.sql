@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+    id UUID PRIMARY KEY,
+    provider TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'processed', 'ignored', 'failed')),
+    error TEXT DEFAULT NULL,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    received_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    claimed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    processed_at TIMESTAMP DEFAULT NULL,
+    UNIQUE (provider, event_id)
+);
+
+CREATE INDEX webhook_events_status_idx ON webhook_events (status, received_at);
+
+-- +goose Down
+DROP TABLE webhook_events;
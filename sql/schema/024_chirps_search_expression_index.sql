@@ -0,0 +1,18 @@
+-- +goose Up
+-- Search through an expression index instead of a stored column, so reading
+-- chirps doesn't carry the tsvector along with every row.
+CREATE INDEX chirps_body_search_idx ON chirps USING GIN (to_tsvector('english', body));
+
+DROP INDEX chirps_search_vector_idx;
+
+ALTER TABLE chirps
+DROP COLUMN search_vector;
+
+-- +goose Down
+ALTER TABLE chirps
+ADD COLUMN search_vector tsvector
+GENERATED ALWAYS AS (to_tsvector('english', body)) STORED;
+
+CREATE INDEX chirps_search_vector_idx ON chirps USING GIN (search_vector);
+
+DROP INDEX chirps_body_search_idx;